@@ -0,0 +1,54 @@
+// Command dealcheck deals a game again from the seeds revealed in its
+// settlement, without the server, so a player can check the shuffle. Pass
+// the committed hash and the server seed, then each seat's client seed in
+// seat order, "" for a seat that added none:
+//
+//	dealcheck -hash 5d41... -seed 9c1f... "my seed" "" "" ""
+//
+// It prints every seat's hand, and exits with status 1 if the seed does
+// not match the hash it was committed to.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+func main() {
+	hash := flag.String("hash", "", "seed hash shown with the deal; checked if set")
+	seed := flag.String("seed", "", "server seed revealed in the settlement, in hex")
+	size := flag.Int("size", 13, "cards dealt to each seat (10 for Sâm Lốc)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: dealcheck -seed HEX [-hash HEX] [-size N] CLIENT_SEED...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *seed == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	proof := &models.DeckProof{SeedHash: *hash, ServerSeed: *seed, ClientSeeds: flag.Args()}
+	if proof.SeedHash == "" {
+		// Nothing to check against: show the hash to compare by eye.
+		raw, err := hex.DecodeString(*seed)
+		if err != nil {
+			fmt.Printf("server seed is not hex: %v\n", err)
+			os.Exit(1)
+		}
+		proof.SeedHash = models.SeedHash(raw)
+		fmt.Printf("seed hash %s\n", proof.SeedHash)
+	}
+	hands, err := proof.Deal(*size)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for seat, hand := range hands {
+		fmt.Printf("seat %d: %s\n", seat, models.FormatCards(hand))
+	}
+}
@@ -0,0 +1,92 @@
+// Command replaycheck plays recorded games again under the current rules
+// and reports where the record disagrees: illegal moves, games that should
+// or should not have ended, and settlements that differ. Games dealt from
+// seeds are also dealt again to check every hand. Games are loaded
+// from the database by ID, using the server's DB_* environment, or read
+// from files in the text notation with -file.
+//
+//	replaycheck 9f2c41d0e5a7b3c8 1b7e0d...
+//	replaycheck -file ticket-1234.txt
+//
+// It exits with status 1 if any game disagrees.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/game-playzui/tienlen-server/internal/config"
+	"github.com/game-playzui/tienlen-server/internal/game"
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/repository"
+)
+
+func main() {
+	files := flag.Bool("file", false, "read games from notation files instead of the database")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: replaycheck [-file] GAME...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	load := loadFile
+	if !*files {
+		db, err := sql.Open("postgres", config.Load().DSN())
+		if err != nil {
+			log.Fatalf("failed to connect to database: %v", err)
+		}
+		defer db.Close()
+		history := repository.NewHistoryRepo(db)
+		load = func(id string) (*models.GameReplay, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			rep, err := history.GetReplay(ctx, id)
+			if err == nil && rep == nil {
+				err = fmt.Errorf("no such game")
+			}
+			return rep, err
+		}
+	}
+
+	failed := false
+	for _, name := range flag.Args() {
+		rep, err := load(name)
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			failed = true
+			continue
+		}
+		diffs := append(game.VerifyGame(rep), game.VerifyDeal(rep)...)
+		if len(diffs) == 0 {
+			fmt.Printf("%s: ok (%s, %d moves)\n", name, rep.Status, len(rep.Moves))
+			continue
+		}
+		failed = true
+		fmt.Printf("%s: does not match the rules\n", name)
+		for _, d := range diffs {
+			fmt.Printf("  %s\n", d)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func loadFile(path string) (*models.GameReplay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return models.ParseGame(string(data))
+}
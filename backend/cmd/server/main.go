@@ -17,10 +17,12 @@ import (
 
 	"github.com/game-playzui/tienlen-server/internal/auth"
 	"github.com/game-playzui/tienlen-server/internal/bot"
+	"github.com/game-playzui/tienlen-server/internal/clock"
 	"github.com/game-playzui/tienlen-server/internal/config"
 	"github.com/game-playzui/tienlen-server/internal/game"
 	"github.com/game-playzui/tienlen-server/internal/handlers"
 	"github.com/game-playzui/tienlen-server/internal/matchmaking"
+	"github.com/game-playzui/tienlen-server/internal/models"
 	"github.com/game-playzui/tienlen-server/internal/repository"
 	"github.com/game-playzui/tienlen-server/internal/ws"
 )
@@ -60,22 +62,34 @@ func main() {
 	log.Println("connected to Redis")
 
 	userRepo := repository.NewUserRepo(db)
+	goldRepo := repository.NewGoldRepo(db)
+	historyRepo := repository.NewHistoryRepo(db)
+	if n, err := goldRepo.ReleaseAllEscrows(context.Background()); err != nil {
+		log.Fatalf("failed to release stale escrows: %v", err)
+	} else if n > 0 {
+		log.Printf("released %d stale escrow holds", n)
+	}
 	jwtService := auth.NewJWTService(cfg.JWTSecret)
 
 	hub := ws.NewHub()
+	configureRooms(hub, cfg)
 	go hub.Run()
 
 	mm := matchmaking.NewService(rdb, hub)
 	go mm.Start()
 
-	_ = game.NewEngine(hub, mm)
+	clk, rng := clock.Real{}, models.CryptoRand{}
+	admins := auth.NewAdmins(cfg.AdminUsers)
+	engine := game.NewEngine(hub, mm, goldRepo, historyRepo, bot.NewTakeover(rng), clk, rng)
+	engine.SetAdmins(admins)
 
-	botManager := bot.NewManager(hub)
+	botManager := bot.NewManager(hub, clk, rng)
 	go botManager.Run()
 
 	authHandler := handlers.NewAuthHandler(userRepo, jwtService)
 	roomHandler := handlers.NewRoomHandler(hub, mm)
-	userHandler := handlers.NewUserHandler(userRepo)
+	userHandler := handlers.NewUserHandler(userRepo, goldRepo, historyRepo)
+	gameHandler := handlers.NewGameHandler(historyRepo, admins)
 	wsHandler := handlers.NewWSHandler(hub, jwtService, userRepo, mm)
 
 	r := mux.NewRouter()
@@ -88,6 +102,10 @@ func main() {
 	protected := api.PathPrefix("").Subrouter()
 	protected.Use(auth.Middleware(jwtService))
 	protected.HandleFunc("/user/profile", userHandler.Profile).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/user/transactions", userHandler.Transactions).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/user/games", userHandler.Games).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/games/{id}/replay", gameHandler.Replay).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/games/{id}/deal", gameHandler.Deal).Methods("GET", "OPTIONS")
 	protected.HandleFunc("/rooms", roomHandler.ListRooms).Methods("GET", "OPTIONS")
 
 	r.HandleFunc("/ws", wsHandler.HandleUpgrade)
@@ -126,6 +144,7 @@ func main() {
 	log.Println("server stopped")
 }
 
+// setSeats resizes the given rooms before any player can join them.
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
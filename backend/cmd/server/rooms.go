@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+
+	"github.com/game-playzui/tienlen-server/internal/config"
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/ws"
+)
+
+// configureRooms applies the per-room settings from cfg to the hub's rooms
+// before any of them are played.
+func configureRooms(hub *ws.Hub, cfg *config.Config) {
+	mienBac := forRooms(hub, cfg.MienBacRooms, func(room *models.Room) {
+		room.Rules.Variant = models.VariantMienBac
+	})
+	if mienBac > 0 {
+		log.Printf("%d rooms play Mien Bac rules", mienBac)
+	}
+	samLoc := forRooms(hub, cfg.SamLocRooms, func(room *models.Room) {
+		room.Rules.Game = models.GameSamLoc
+		room.Rules.InstantWins = models.DefaultInstantWins(models.GameSamLoc)
+	})
+	if samLoc > 0 {
+		log.Printf("%d rooms play Sâm Lốc", samLoc)
+	}
+	setSeats(hub, cfg.TwoSeatRooms, 2)
+	setSeats(hub, cfg.ThreeSeatRooms, 3)
+	speed := forRooms(hub, cfg.SpeedRooms, func(room *models.Room) {
+		room.TurnTimer = models.SpeedTurnTimer
+		room.TimeBank = models.SpeedTimeBank
+	})
+	if speed > 0 {
+		log.Printf("%d rooms play %d-second turns", speed, models.SpeedTurnTimer)
+	}
+	fullRanking := forRooms(hub, cfg.FullRankingRooms, func(room *models.Room) {
+		room.Rules.FullRanking = true
+	})
+	if fullRanking > 0 {
+		log.Printf("%d rooms play for every place", fullRanking)
+	}
+	chosen := 0
+	for id, names := range cfg.InstantWins {
+		if room := hub.GetRoom(id); room != nil {
+			room.Rules.InstantWins = instantWins(room.Rules.Game, names)
+			chosen++
+		}
+	}
+	if chosen > 0 {
+		log.Printf("%d rooms choose their own instant wins", chosen)
+	}
+}
+
+// instantWins returns the named instant wins, in the order names lists them,
+// leaving out any that game does not deal.
+func instantWins(game models.GameType, names []string) []models.InstantWin {
+	played := models.RoomRules{InstantWins: models.DefaultInstantWins(game)}
+	wins := []models.InstantWin{}
+	for _, name := range names {
+		kind := models.InstantWin(name)
+		if !played.InstantWinEnabled(kind) {
+			log.Printf("instant win %q is not played at %s tables, ignoring it", name, game)
+			continue
+		}
+		wins = append(wins, kind)
+	}
+	return wins
+}
+
+func setSeats(hub *ws.Hub, roomIDs []int, seats int) {
+	n := forRooms(hub, roomIDs, func(room *models.Room) {
+		room.Seats = seats
+	})
+	if n > 0 {
+		log.Printf("%d rooms seat %d players", n, seats)
+	}
+}
+
+// forRooms calls apply on each listed room that exists and returns how many
+// there were.
+func forRooms(hub *ws.Hub, roomIDs []int, apply func(*models.Room)) int {
+	n := 0
+	for _, id := range roomIDs {
+		if room := hub.GetRoom(id); room != nil {
+			apply(room)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/game-playzui/tienlen-server/internal/config"
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/ws"
+)
+
+func TestConfigureRoomsFullRanking(t *testing.T) {
+	t.Setenv("FULL_RANKING_ROOMS", "2-3,9")
+	hub := ws.NewHub()
+	configureRooms(hub, config.Load())
+
+	for id, want := range map[int]bool{1: false, 2: true, 3: true, 4: false, 9: true} {
+		if got := hub.GetRoom(id).Rules.FullRanking; got != want {
+			t.Errorf("room %d FullRanking = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestConfigureRoomsInstantWins(t *testing.T) {
+	t.Setenv("SAM_LOC_ROOMS", "2,4")
+	t.Setenv("INSTANT_WINS", "3:six_pairs dragon;4:five_pairs sam_dragon six_pairs;5:")
+	hub := ws.NewHub()
+	configureRooms(hub, config.Load())
+
+	tests := []struct {
+		room int
+		want []models.InstantWin
+	}{
+		{1, models.DefaultInstantWins(models.GameTienLen)},
+		{2, models.DefaultInstantWins(models.GameSamLoc)},
+		{3, []models.InstantWin{models.InstantWinSixPairs, models.InstantWinDragon}},
+		{4, []models.InstantWin{models.InstantWinFivePairs, models.InstantWinSamDragon}},
+		{5, []models.InstantWin{}},
+	}
+	for _, tt := range tests {
+		if got := hub.GetRoom(tt.room).Rules.InstantWins; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("room %d InstantWins = %v, want %v", tt.room, got, tt.want)
+		}
+	}
+}
+
+func TestConfigureRoomsSpeed(t *testing.T) {
+	t.Setenv("SPEED_ROOMS", "1,3")
+	hub := ws.NewHub()
+	configureRooms(hub, config.Load())
+
+	for id, speed := range map[int]bool{1: true, 2: false, 3: true} {
+		room := hub.GetRoom(id)
+		wantTimer, wantBank := models.DefaultTurnTimer, models.DefaultTimeBank
+		if speed {
+			wantTimer, wantBank = models.SpeedTurnTimer, models.SpeedTimeBank
+		}
+		if room.TurnTimer != wantTimer || room.TimeBank != wantBank {
+			t.Errorf("room %d timer/bank = %d/%d, want %d/%d", id, room.TurnTimer, room.TimeBank, wantTimer, wantBank)
+		}
+	}
+}
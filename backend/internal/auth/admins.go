@@ -0,0 +1,17 @@
+package auth
+
+// Admins are the users who may see what only a game's players otherwise
+// can, such as every hand of a game they did not play.
+type Admins map[int64]bool
+
+func NewAdmins(userIDs []int) Admins {
+	a := make(Admins, len(userIDs))
+	for _, id := range userIDs {
+		a[int64(id)] = true
+	}
+	return a
+}
+
+func (a Admins) Has(userID int64) bool {
+	return a[userID]
+}
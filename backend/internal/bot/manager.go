@@ -3,11 +3,11 @@ package bot
 import (
 	"fmt"
 	"log"
-	"math/rand"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/game-playzui/tienlen-server/internal/clock"
 	"github.com/game-playzui/tienlen-server/internal/models"
 	"github.com/game-playzui/tienlen-server/internal/ws"
 )
@@ -38,26 +38,38 @@ const (
 )
 
 type Manager struct {
-	hub     *ws.Hub
-	bots    map[int64]*BotPlayer // botUserID -> BotPlayer
-	mu      sync.Mutex
+	hub   *ws.Hub
+	clock clock.Clock
+	rng   models.Rand
+	bots  map[int64]*BotPlayer // botUserID -> BotPlayer
+	mu    sync.Mutex
 }
 
-func NewManager(hub *ws.Hub) *Manager {
+// NewManager returns a bot manager for the hub. Its bots wait and choose
+// using clk and rng; nil falls back to the wall clock and crypto/rand.
+func NewManager(hub *ws.Hub, clk clock.Clock, rng models.Rand) *Manager {
+	if clk == nil {
+		clk = clock.Real{}
+	}
+	if rng == nil {
+		rng = models.CryptoRand{}
+	}
 	return &Manager{
-		hub:  hub,
-		bots: make(map[int64]*BotPlayer),
+		hub:   hub,
+		clock: clk,
+		rng:   rng,
+		bots:  make(map[int64]*BotPlayer),
 	}
 }
 
 func (m *Manager) Run() {
 	m.setupDedicatedRooms()
 
-	ticker := time.NewTicker(AutoFillInterval)
-	defer ticker.Stop()
-
+	tick := make(chan struct{}, 1)
 	log.Println("bot manager started")
-	for range ticker.C {
+	for {
+		m.clock.AfterFunc(AutoFillInterval, func() { tick <- struct{}{} })
+		<-tick
 		m.autoFillRooms()
 	}
 }
@@ -66,60 +78,63 @@ func (m *Manager) setupDedicatedRooms() {
 	antes := []int{100, 500, 1000}
 	for _, ante := range antes {
 		count := 0
-		for roomID, room := range m.hub.Rooms {
+		for roomID := range m.hub.Rooms {
 			if count >= DedicatedRoomsPerAnte {
 				break
 			}
-			room.RLock()
-			isMatch := room.AnteAmount == ante && room.Phase == models.PhaseLobby && room.PlayerCount() == 0
-			room.RUnlock()
-
-			if !isMatch {
+			info, _ := m.hub.RoomInfo(roomID)
+			if info.AnteAmount != ante || info.Phase != models.PhaseLobby || info.PlayerCount != 0 {
 				continue
 			}
 
-			room.Lock()
-			if room.PlayerCount() != 0 || room.Phase != models.PhaseLobby {
-				room.Unlock()
+			bots := 0
+			m.hub.DoWait(roomID, func(room *models.Room) {
+				if room.PlayerCount() != 0 || room.Phase != models.PhaseLobby {
+					return
+				}
+				room.HasBots = true
+				room.Name = fmt.Sprintf("Bot Room %d (%dG)", roomID, ante)
+				bots = room.Seats - 1
+			})
+			if bots == 0 {
 				continue
 			}
-			room.HasBots = true
-			room.Name = fmt.Sprintf("Bot Room %d (%dG)", roomID, ante)
-			room.Unlock()
 
-			m.addBotsToRoom(room, 3)
+			m.addBotsToRoom(roomID, bots)
 			count++
-			log.Printf("set up dedicated bot room %d (%dG) with 3 bots", roomID, ante)
+			log.Printf("set up dedicated bot room %d (%dG) with %d bots", roomID, ante, bots)
 		}
 	}
 }
 
-func (m *Manager) addBotsToRoom(room *models.Room, count int) {
+func (m *Manager) addBotsToRoom(roomID int, count int) {
 	for i := 0; i < count; i++ {
 		botID := nextBotID()
-		name := botNames[rand.Intn(len(botNames))]
-		diff := Difficulty(rand.Intn(3))
+		name := botNames[m.rng.Intn(len(botNames))]
+		diff := Difficulty(m.rng.Intn(3))
 
 		client := ws.NewBotClient(m.hub, botID, name)
 		m.hub.RegisterBotClient(client)
 
-		room.Lock()
-		seat := room.FindEmptySeat()
+		seat := -1
+		m.hub.DoWait(roomID, func(room *models.Room) {
+			if seat = room.FindEmptySeat(); seat < 0 {
+				return
+			}
+			room.Players[seat] = &models.Player{
+				UserID:    botID,
+				Username:  name,
+				SeatIndex: seat,
+				IsBot:     true,
+			}
+			client.SetRoom(room.ID)
+		})
 		if seat < 0 {
-			room.Unlock()
 			m.hub.UnregisterBotClient(client)
 			break
 		}
-		room.Players[seat] = &models.Player{
-			UserID:    botID,
-			Username:  name,
-			SeatIndex: seat,
-			IsBot:     true,
-		}
-		client.SetRoom(room.ID)
-		room.Unlock()
 
-		bp := NewBotPlayer(client, room.ID, seat, diff)
+		bp := NewBotPlayer(client, roomID, seat, diff, m.clock, m.rng)
 		bp.Start()
 
 		m.mu.Lock()
@@ -127,46 +142,36 @@ func (m *Manager) addBotsToRoom(room *models.Room, count int) {
 		m.mu.Unlock()
 
 		// Auto-ready the bot
-		go func() {
-			time.Sleep(500 * time.Millisecond)
-			bp.sendReady()
-		}()
+		m.clock.AfterFunc(500*time.Millisecond, bp.sendReady)
 	}
 }
 
 func (m *Manager) autoFillRooms() {
-	now := time.Now()
-	for _, room := range m.hub.Rooms {
-		room.RLock()
-		phase := room.Phase
-		humanCount := room.HumanPlayerCount()
-		playerCount := room.PlayerCount()
-		hasBots := room.HasBots
-		waitingSince := room.WaitingSince
-		room.RUnlock()
-
-		if phase != models.PhaseLobby || humanCount == 0 || playerCount >= 4 {
+	now := m.clock.Now()
+	for roomID := range m.hub.Rooms {
+		info, _ := m.hub.RoomInfo(roomID)
+		if info.Phase != models.PhaseLobby || info.PlayerCount == 0 || info.PlayerCount >= info.Seats || info.HasBots {
 			continue
 		}
 
-		if hasBots {
-			continue
-		}
-
-		if waitingSince == nil || now.Sub(*waitingSince) < AutoFillWaitThreshold {
-			continue
-		}
-
-		botsNeeded := 4 - playerCount
+		botsNeeded := 0
+		m.hub.DoWait(roomID, func(room *models.Room) {
+			if room.Phase != models.PhaseLobby || room.HumanPlayerCount() == 0 || room.HasBots {
+				return
+			}
+			if room.WaitingSince == nil || now.Sub(*room.WaitingSince) < AutoFillWaitThreshold {
+				return
+			}
+			botsNeeded = room.Seats - room.PlayerCount()
+			if botsNeeded > 0 {
+				room.HasBots = true
+			}
+		})
 		if botsNeeded <= 0 {
 			continue
 		}
 
-		room.Lock()
-		room.HasBots = true
-		room.Unlock()
-
-		log.Printf("auto-filling room %d with %d bots (human players waiting)", room.ID, botsNeeded)
-		m.addBotsToRoom(room, botsNeeded)
+		log.Printf("auto-filling room %d with %d bots (human players waiting)", roomID, botsNeeded)
+		m.addBotsToRoom(roomID, botsNeeded)
 	}
 }
@@ -3,9 +3,10 @@ package bot
 import (
 	"encoding/json"
 	"log"
-	"math/rand"
 	"time"
 
+	"github.com/game-playzui/tienlen-server/internal/clock"
+	"github.com/game-playzui/tienlen-server/internal/game"
 	"github.com/game-playzui/tienlen-server/internal/models"
 	"github.com/game-playzui/tienlen-server/internal/ws"
 )
@@ -17,15 +18,21 @@ type BotPlayer struct {
 	Difficulty    Difficulty
 	hand          []models.Card
 	lastTablePlay *movePlayedPayload
+	rules         game.RuleSet
+	clock         clock.Clock
+	rng           models.Rand
 	stopCh        chan struct{}
 }
 
-func NewBotPlayer(client *ws.Client, roomID, seat int, diff Difficulty) *BotPlayer {
+func NewBotPlayer(client *ws.Client, roomID, seat int, diff Difficulty, clk clock.Clock, rng models.Rand) *BotPlayer {
 	return &BotPlayer{
 		Client:     client,
 		RoomID:     roomID,
 		SeatIndex:  seat,
 		Difficulty: diff,
+		rules:      game.MienNam{},
+		clock:      clk,
+		rng:        rng,
 		stopCh:     make(chan struct{}),
 	}
 }
@@ -84,7 +91,11 @@ type cardDealtPayload struct {
 	Players     []struct {
 		SeatIndex int `json:"seat_index"`
 	} `json:"players"`
-	TablePlay *json.RawMessage `json:"table_play"`
+	TablePlay   *json.RawMessage `json:"table_play"`
+	OpeningCard *models.Card     `json:"opening_card"`
+	Variant     models.Variant   `json:"variant"`
+	Game        models.GameType  `json:"game"`
+	Phase       models.GamePhase `json:"phase"`
 }
 
 func (bp *BotPlayer) onCardDealt(payload json.RawMessage) {
@@ -93,11 +104,21 @@ func (bp *BotPlayer) onCardDealt(payload json.RawMessage) {
 		return
 	}
 	bp.hand = p.Hand
+	if p.Game == models.GameSamLoc {
+		bp.rules = game.SamLoc{}
+	} else if rules, ok := game.LookupRuleSet(p.Variant); ok {
+		bp.rules = rules
+	}
 	log.Printf("bot %s: received %d cards, current_turn=%d, my_seat=%d",
 		bp.Client.Username, len(bp.hand), p.CurrentTurn, bp.SeatIndex)
 
-	if p.CurrentTurn == bp.SeatIndex {
-		bp.playTurn(nil)
+	// Sâm Lốc play starts with a turn_change once declarations close.
+	if p.CurrentTurn == bp.SeatIndex && p.Phase == models.PhasePlaying {
+		if p.OpeningCard != nil {
+			bp.playTurn(&TableState{IsEmpty: true, MustInclude: p.OpeningCard})
+		} else {
+			bp.playTurn(nil)
+		}
 	}
 }
 
@@ -115,8 +136,8 @@ func (bp *BotPlayer) onGameState(payload json.RawMessage) {
 }
 
 type movePlayedPayload struct {
-	PlayerIndex int                  `json:"player_index"`
-	Cards       []models.Card        `json:"cards"`
+	PlayerIndex int                    `json:"player_index"`
+	Cards       []models.Card          `json:"cards"`
 	ComboType   models.CombinationType `json:"combo_type"`
 }
 
@@ -132,9 +153,10 @@ func (bp *BotPlayer) onMovePlayed(payload json.RawMessage) {
 }
 
 type turnChangePayload struct {
-	CurrentTurn int    `json:"current_turn"`
-	TableClear  bool   `json:"table_clear"`
-	Action      string `json:"action,omitempty"`
+	CurrentTurn int          `json:"current_turn"`
+	TableClear  bool         `json:"table_clear"`
+	Action      string       `json:"action,omitempty"`
+	OpeningCard *models.Card `json:"opening_card,omitempty"`
 }
 
 func (bp *BotPlayer) onTurnChange(payload json.RawMessage) {
@@ -148,7 +170,9 @@ func (bp *BotPlayer) onTurnChange(payload json.RawMessage) {
 	}
 
 	var table *TableState
-	if p.TableClear {
+	if p.TableClear && p.OpeningCard != nil {
+		table = &TableState{IsEmpty: true, MustInclude: p.OpeningCard}
+	} else if p.TableClear {
 		table = nil
 	} else if bp.lastTablePlay != nil {
 		table = &TableState{
@@ -161,22 +185,29 @@ func (bp *BotPlayer) onTurnChange(payload json.RawMessage) {
 }
 
 func (bp *BotPlayer) playTurn(table *TableState) {
-	delay := time.Duration(1000+rand.Intn(2000)) * time.Millisecond
-	go func() {
-		select {
-		case <-time.After(delay):
-		case <-bp.stopCh:
+	delay := time.Duration(1000+bp.rng.Intn(2000)) * time.Millisecond
+	bp.clock.AfterFunc(delay, func() {
+		if bp.stopped() {
 			return
 		}
 
-		play := ChoosePlay(bp.hand, table, bp.Difficulty)
+		play := ChoosePlay(bp.rules, bp.hand, table, bp.Difficulty, bp.rng)
 		if play == nil {
 			bp.sendPass()
 			return
 		}
 
 		bp.sendPlay(play.Cards)
-	}()
+	})
+}
+
+func (bp *BotPlayer) stopped() bool {
+	select {
+	case <-bp.stopCh:
+		return true
+	default:
+		return false
+	}
 }
 
 func (bp *BotPlayer) sendPlay(cards []models.Card) {
@@ -204,17 +235,13 @@ func (bp *BotPlayer) sendReady() {
 func (bp *BotPlayer) onSettlement() {
 	bp.hand = nil
 	bp.lastTablePlay = nil
-	delay := time.Duration(2000+rand.Intn(1000)) * time.Millisecond
-	go func() {
-		select {
-		case <-time.After(delay):
-		case <-bp.stopCh:
-			return
+	delay := time.Duration(2000+bp.rng.Intn(1000)) * time.Millisecond
+	// Wait for room to reset to LOBBY, then auto-ready
+	bp.clock.AfterFunc(delay+6*time.Second, func() {
+		if !bp.stopped() {
+			bp.sendReady()
 		}
-		// Wait for room to reset to LOBBY, then auto-ready
-		time.Sleep(6 * time.Second)
-		bp.sendReady()
-	}()
+	})
 }
 
 func (bp *BotPlayer) onRoomUpdate(payload json.RawMessage) {
@@ -1,7 +1,6 @@
 package bot
 
 import (
-	"math/rand"
 	"sort"
 
 	"github.com/game-playzui/tienlen-server/internal/game"
@@ -21,22 +20,30 @@ type Play struct {
 	ComboType models.CombinationType
 }
 
-func ChoosePlay(hand []models.Card, table *TableState, diff Difficulty) *Play {
+// ChoosePlay picks a move under the given rule set. Easy bots pick at
+// random from rng. A nil result means pass.
+func ChoosePlay(rules game.RuleSet, hand []models.Card, table *TableState, diff Difficulty, rng models.Rand) *Play {
+	if table != nil && table.IsEmpty && table.MustInclude != nil {
+		return chooseLeadWith(rules, hand, *table.MustInclude)
+	}
 	if table == nil || table.IsEmpty {
-		return chooseOpening(hand, diff)
+		return chooseOpening(rules, hand, diff, rng)
 	}
-	return chooseBeat(hand, table, diff)
+	return chooseBeat(rules, hand, table, diff, rng)
 }
 
 type TableState struct {
 	IsEmpty   bool
 	Cards     []models.Card
 	ComboType models.CombinationType
+	// MustInclude is a card the lead has to contain, e.g. the 3 of spades
+	// on a table's opening game.
+	MustInclude *models.Card
 }
 
 // chooseOpening picks a combination to lead with when the table is clear.
-func chooseOpening(hand []models.Card, diff Difficulty) *Play {
-	combos := decomposeHand(hand)
+func chooseOpening(rules game.RuleSet, hand []models.Card, diff Difficulty, rng models.Rand) *Play {
+	combos := decomposeHand(rules, hand)
 	if len(combos) == 0 {
 		return &Play{Cards: []models.Card{lowestCard(hand)}, ComboType: models.ComboSingle}
 	}
@@ -47,7 +54,7 @@ func chooseOpening(hand []models.Card, diff Difficulty) *Play {
 
 	switch diff {
 	case DiffEasy:
-		idx := rand.Intn(len(combos))
+		idx := rng.Intn(len(combos))
 		return combos[idx]
 	case DiffMedium:
 		return combos[0]
@@ -57,6 +64,17 @@ func chooseOpening(hand []models.Card, diff Difficulty) *Play {
 	return combos[0]
 }
 
+// chooseLeadWith leads with whichever combination in the hand's
+// decomposition holds the required card, or the card on its own.
+func chooseLeadWith(rules game.RuleSet, hand []models.Card, must models.Card) *Play {
+	for _, c := range decomposeHand(rules, hand) {
+		if models.ContainsCard(c.Cards, must) {
+			return c
+		}
+	}
+	return &Play{Cards: []models.Card{must}, ComboType: models.ComboSingle}
+}
+
 // pickSmartOpening for hard bots: play lowest combo, but prefer sequences
 // to break up fewer pairs/triples.
 func pickSmartOpening(hand []models.Card, combos []*Play) *Play {
@@ -69,8 +87,8 @@ func pickSmartOpening(hand []models.Card, combos []*Play) *Play {
 }
 
 // chooseBeat finds the best play to beat the current table.
-func chooseBeat(hand []models.Card, table *TableState, diff Difficulty) *Play {
-	candidates := findBeatingPlays(hand, table)
+func chooseBeat(rules game.RuleSet, hand []models.Card, table *TableState, diff Difficulty, rng models.Rand) *Play {
+	candidates := findBeatingPlays(rules, hand, table)
 	if len(candidates) == 0 {
 		return nil // pass
 	}
@@ -81,7 +99,7 @@ func chooseBeat(hand []models.Card, table *TableState, diff Difficulty) *Play {
 
 	switch diff {
 	case DiffEasy:
-		return candidates[rand.Intn(len(candidates))]
+		return candidates[rng.Intn(len(candidates))]
 	case DiffMedium:
 		return candidates[0]
 	case DiffHard:
@@ -114,7 +132,7 @@ func pickSmartBeat(hand []models.Card, candidates []*Play, table *TableState) *P
 }
 
 // findBeatingPlays enumerates all subsets of hand that can beat the table.
-func findBeatingPlays(hand []models.Card, table *TableState) []*Play {
+func findBeatingPlays(rules game.RuleSet, hand []models.Card, table *TableState) []*Play {
 	var results []*Play
 	tablePlay := &models.TablePlay{Cards: table.Cards, ComboType: table.ComboType}
 
@@ -122,52 +140,48 @@ func findBeatingPlays(hand []models.Card, table *TableState) []*Play {
 	case models.ComboSingle:
 		for _, c := range hand {
 			cards := []models.Card{c}
-			if game.CanBeat(tablePlay, cards, models.ComboSingle) {
+			if rules.CanBeat(tablePlay, cards, models.ComboSingle) {
 				results = append(results, &Play{Cards: cards, ComboType: models.ComboSingle})
 			}
 		}
 		if table.Cards[0].Rank == models.Two {
-			results = append(results, findChopPlays(hand)...)
+			results = append(results, findChopPlays(rules, tablePlay, hand)...)
 		}
 	case models.ComboPair:
 		pairs := findPairs(hand)
 		for _, p := range pairs {
-			if game.CanBeat(tablePlay, p, models.ComboPair) {
+			if rules.CanBeat(tablePlay, p, models.ComboPair) {
 				results = append(results, &Play{Cards: p, ComboType: models.ComboPair})
 			}
 		}
 		if table.Cards[0].Rank == models.Two {
-			for _, ds := range findDoubleSequences(hand) {
-				if len(ds) >= 8 {
-					results = append(results, &Play{Cards: ds, ComboType: models.ComboDoubleSequence})
-				}
-			}
+			results = append(results, findChopPlays(rules, tablePlay, hand)...)
 		}
 	case models.ComboTriple:
 		triples := findTriples(hand)
 		for _, t := range triples {
-			if game.CanBeat(tablePlay, t, models.ComboTriple) {
+			if rules.CanBeat(tablePlay, t, models.ComboTriple) {
 				results = append(results, &Play{Cards: t, ComboType: models.ComboTriple})
 			}
 		}
 	case models.ComboSequence:
 		seqs := findSequences(hand, len(table.Cards))
 		for _, s := range seqs {
-			if game.CanBeat(tablePlay, s, models.ComboSequence) {
+			if rules.CanBeat(tablePlay, s, models.ComboSequence) {
 				results = append(results, &Play{Cards: s, ComboType: models.ComboSequence})
 			}
 		}
 	case models.ComboDoubleSequence:
 		dseqs := findDoubleSequences(hand)
 		for _, ds := range dseqs {
-			if len(ds) == len(table.Cards) && game.CanBeat(tablePlay, ds, models.ComboDoubleSequence) {
+			if len(ds) == len(table.Cards) && rules.CanBeat(tablePlay, ds, models.ComboDoubleSequence) {
 				results = append(results, &Play{Cards: ds, ComboType: models.ComboDoubleSequence})
 			}
 		}
 	case models.ComboFourOfAKind:
 		fours := findFourOfAKinds(hand)
 		for _, f := range fours {
-			if game.CanBeat(tablePlay, f, models.ComboFourOfAKind) {
+			if rules.CanBeat(tablePlay, f, models.ComboFourOfAKind) {
 				results = append(results, &Play{Cards: f, ComboType: models.ComboFourOfAKind})
 			}
 		}
@@ -176,15 +190,17 @@ func findBeatingPlays(hand []models.Card, table *TableState) []*Play {
 	return results
 }
 
-func findChopPlays(hand []models.Card) []*Play {
+func findChopPlays(rules game.RuleSet, tablePlay *models.TablePlay, hand []models.Card) []*Play {
 	var results []*Play
 	fours := findFourOfAKinds(hand)
 	for _, f := range fours {
-		results = append(results, &Play{Cards: f, ComboType: models.ComboFourOfAKind})
+		if rules.CanBeat(tablePlay, f, models.ComboFourOfAKind) {
+			results = append(results, &Play{Cards: f, ComboType: models.ComboFourOfAKind})
+		}
 	}
 	dseqs := findDoubleSequences(hand)
 	for _, ds := range dseqs {
-		if len(ds) >= 6 {
+		if rules.CanBeat(tablePlay, ds, models.ComboDoubleSequence) {
 			results = append(results, &Play{Cards: ds, ComboType: models.ComboDoubleSequence})
 		}
 	}
@@ -192,7 +208,7 @@ func findChopPlays(hand []models.Card) []*Play {
 }
 
 // decomposeHand breaks a hand into playable combinations, preferring larger combos.
-func decomposeHand(hand []models.Card) []*Play {
+func decomposeHand(rules game.RuleSet, hand []models.Card) []*Play {
 	models.SortCards(hand)
 	var plays []*Play
 
@@ -202,8 +218,11 @@ func decomposeHand(hand []models.Card) []*Play {
 	sort.Slice(seqs, func(i, j int) bool { return len(seqs[i]) > len(seqs[j]) })
 	for _, seq := range seqs {
 		if canUse(hand, seq, used) {
+			ct, ok := rules.Classify(seq)
+			if !ok {
+				continue
+			}
 			markUsed(hand, seq, used)
-			ct, _ := game.ClassifyCombination(seq)
 			plays = append(plays, &Play{Cards: seq, ComboType: ct})
 		}
 	}
@@ -211,8 +230,11 @@ func decomposeHand(hand []models.Card) []*Play {
 	dseqs := findDoubleSequences(hand)
 	for _, ds := range dseqs {
 		if canUse(hand, ds, used) {
+			ct, ok := rules.Classify(ds)
+			if !ok {
+				continue
+			}
 			markUsed(hand, ds, used)
-			ct, _ := game.ClassifyCombination(ds)
 			plays = append(plays, &Play{Cards: ds, ComboType: ct})
 		}
 	}
@@ -220,15 +242,13 @@ func decomposeHand(hand []models.Card) []*Play {
 	remaining := unusedCards(hand, used)
 	byRank := groupByRank(remaining)
 	for _, group := range byRank {
-		switch len(group) {
-		case 4:
-			plays = append(plays, &Play{Cards: group, ComboType: models.ComboFourOfAKind})
-		case 3:
-			plays = append(plays, &Play{Cards: group, ComboType: models.ComboTriple})
-		case 2:
-			plays = append(plays, &Play{Cards: group, ComboType: models.ComboPair})
-		case 1:
-			plays = append(plays, &Play{Cards: group, ComboType: models.ComboSingle})
+		// Groups the rule set does not allow together are played as singles.
+		if ct, ok := rules.Classify(group); ok {
+			plays = append(plays, &Play{Cards: group, ComboType: ct})
+			continue
+		}
+		for _, c := range group {
+			plays = append(plays, &Play{Cards: []models.Card{c}, ComboType: models.ComboSingle})
 		}
 	}
 
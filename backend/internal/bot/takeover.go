@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"github.com/game-playzui/tienlen-server/internal/game"
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+// Takeover plays on behalf of a human who disconnected or went AFK, using
+// the same strategy as the room bots.
+type Takeover struct {
+	Difficulty Difficulty
+	rng        models.Rand
+}
+
+// NewTakeover returns a medium-strength takeover player. A nil rng uses
+// crypto/rand.
+func NewTakeover(rng models.Rand) *Takeover {
+	if rng == nil {
+		rng = models.CryptoRand{}
+	}
+	return &Takeover{Difficulty: DiffMedium, rng: rng}
+}
+
+// ChooseMove implements game.AutoPlayer. A nil result means pass.
+func (t *Takeover) ChooseMove(rules game.RuleSet, hand []models.Card, table *models.TablePlay) []models.Card {
+	if len(hand) == 0 {
+		return nil
+	}
+	var ts *TableState
+	if table != nil {
+		ts = &TableState{Cards: table.Cards, ComboType: table.ComboType}
+	}
+	play := ChoosePlay(rules, hand, ts, t.Difficulty, t.rng)
+	if play == nil {
+		return nil
+	}
+	return play.Cards
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -16,6 +17,25 @@ type Config struct {
 	RedisAddr  string
 	RedisPwd   string
 	JWTSecret  string
+
+	// MienBacRooms lists the rooms that play Northern rules, and
+	// SamLocRooms the rooms that play Sâm Lốc instead of Tien Len.
+	MienBacRooms []int
+	SamLocRooms  []int
+	// TwoSeatRooms and ThreeSeatRooms list the short tables; every other
+	// room seats four.
+	TwoSeatRooms   []int
+	ThreeSeatRooms []int
+	// SpeedRooms play short turns with a small time bank.
+	SpeedRooms []int
+	// FullRankingRooms play on until every place is decided.
+	FullRankingRooms []int
+	// InstantWins maps a room to the instant wins it honours in place of
+	// its game's defaults; an empty list turns them all off.
+	InstantWins map[int][]string
+
+	// AdminUsers may see every hand of any finished game.
+	AdminUsers []int
 }
 
 func Load() *Config {
@@ -30,6 +50,17 @@ func Load() *Config {
 		RedisAddr:  getEnv("REDIS_ADDR", "localhost:6379"),
 		RedisPwd:   getEnv("REDIS_PASSWORD", ""),
 		JWTSecret:  getEnv("JWT_SECRET", "dev-secret-key"),
+
+		MienBacRooms:   getEnvIntRanges("MIEN_BAC_ROOMS"),
+		SamLocRooms:    getEnvIntRanges("SAM_LOC_ROOMS"),
+		TwoSeatRooms:   getEnvIntRanges("TWO_SEAT_ROOMS"),
+		ThreeSeatRooms: getEnvIntRanges("THREE_SEAT_ROOMS"),
+		SpeedRooms:     getEnvIntRanges("SPEED_ROOMS"),
+
+		FullRankingRooms: getEnvIntRanges("FULL_RANKING_ROOMS"),
+		InstantWins:      getEnvRoomLists("INSTANT_WINS"),
+
+		AdminUsers: getEnvIntRanges("ADMIN_USERS"),
 	}
 }
 
@@ -57,3 +88,53 @@ func getEnvInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+// getEnvIntRanges parses a comma-separated list of integers and inclusive
+// ranges, e.g. "1-50,901". Malformed entries are skipped.
+func getEnvIntRanges(key string) []int {
+	return parseIntRanges(os.Getenv(key))
+}
+
+// getEnvRoomLists parses semicolon-separated entries of rooms, as read by
+// getEnvIntRanges, and a space-separated list of names, e.g.
+// "1-50,901:dragon six_pairs;60:". A later entry for a room replaces an
+// earlier one; entries without a colon are skipped.
+func getEnvRoomLists(key string) map[int][]string {
+	out := make(map[int][]string)
+	for _, entry := range strings.Split(os.Getenv(key), ";") {
+		rooms, names, ok := strings.Cut(entry, ":")
+		if !ok {
+			continue
+		}
+		list := strings.Fields(names)
+		for _, id := range parseIntRanges(rooms) {
+			out[id] = list
+		}
+	}
+	return out
+}
+
+func parseIntRanges(s string) []int {
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			continue
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+				continue
+			}
+		}
+		for i := from; i <= to; i++ {
+			out = append(out, i)
+		}
+	}
+	return out
+}
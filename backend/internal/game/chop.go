@@ -0,0 +1,193 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/ws"
+)
+
+// pigValue is what chopped 2s are worth in antes: 1 for each black 2
+// (spades, clubs) and 2 for each red 2 (diamonds, hearts).
+func pigValue(cards []models.Card) int {
+	value := 0
+	for _, c := range cards {
+		if isRed(c) {
+			value += 2
+		} else {
+			value++
+		}
+	}
+	return value
+}
+
+// findChop returns the chop made by playing cards over the current table
+// play, or nil if the play is not a chop. Chopping 2s is worth their pig
+// value scaled by the rank of the bomb used, as defined by the room's rule
+// set. Chopping a bomb that was itself a chop passes the whole chain on: the
+// chopped player pays everything they collected plus the rank of the bomb
+// they lose.
+// Must be called before the play replaces room.TablePlay.
+func findChop(room *models.Room, idx int, cards []models.Card, combo models.CombinationType) *models.Chop {
+	rules := RulesFor(room)
+	table := room.TablePlay
+	bomb := rules.BombRank(combo, len(cards))
+	if table == nil || bomb == 0 {
+		return nil
+	}
+
+	chop := &models.Chop{
+		Chopper:      idx,
+		Chopped:      table.PlayerIndex,
+		Cards:        cards,
+		ChoppedCards: table.Cards,
+	}
+	switch {
+	case table.Cards[0].Rank == models.Two &&
+		(table.ComboType == models.ComboSingle || table.ComboType == models.ComboPair):
+		chop.Chain = 1
+		chop.Value = rules.PigValue(table.Cards) * bomb * room.AnteAmount
+	case room.LastChop != nil:
+		chop.Chain = room.LastChop.Chain + 1
+		chop.Value = room.LastChop.Value + rules.BombRank(table.ComboType, len(table.Cards))*room.AnteAmount
+	default:
+		return nil
+	}
+	return chop
+}
+
+// applyChop charges the chopped player, pays the chopper on the spot and
+// announces the chop.
+// Must be called on the room's goroutine.
+func (e *Engine) applyChop(room *models.Room, chop *models.Chop) {
+	chargeChop(room, chop)
+	e.payChop(room, len(room.Chops)-1)
+
+	log.Printf("room %d: seat %d chopped seat %d for %d (chain %d)",
+		room.ID, chop.Chopper, chop.Chopped, chop.Amount, chop.Chain)
+
+	data, _ := ws.NewMessage(ws.MsgChop, chop)
+	e.hub.Broadcast(room, data)
+}
+
+// chargeChop records a chop against the chopped player. The payment is
+// capped so that a player never loses more in one game than the escrow
+// reserved for them.
+func chargeChop(room *models.Room, chop *models.Chop) {
+	chop.Amount = chop.Value
+	if headroom := lossHeadroom(room, chop.Chopped); chop.Amount > headroom {
+		chop.Amount = headroom
+	}
+	room.Chops = append(room.Chops, *chop)
+	room.LastChop = chop
+}
+
+// lossHeadroom is how much more seat can lose this game before exhausting
+// its escrow.
+func lossHeadroom(room *models.Room, seat int) int {
+	headroom := maxLoss(room)
+	for _, c := range room.Chops {
+		if c.Chopped == seat {
+			headroom -= c.Amount
+		}
+	}
+	if headroom < 0 {
+		return 0
+	}
+	return headroom
+}
+
+// chopBooking follows the booking of one game's chops.
+type chopBooking struct {
+	// done is closed once the last chop queued so far is booked or given
+	// up on.
+	done   chan struct{}
+	booked int
+	failed []int
+}
+
+// payChop books the room's chop at index between the two players, after
+// the game's earlier chops. It does not wait for the database, and the chop
+// stands even if the game is later aborted. A chop that cannot be booked
+// is left for the settlement, which books it first.
+// Must be called on the room's goroutine.
+func (e *Engine) payChop(room *models.Room, index int) {
+	if e.gold == nil {
+		return
+	}
+	c := room.Chops[index]
+	from, to := room.Players[c.Chopped], room.Players[c.Chopper]
+	p := &models.ChopPayment{
+		GameID:     room.GameID,
+		RoomID:     room.ID,
+		Index:      index,
+		FromUserID: from.UserID,
+		FromBot:    from.IsBot,
+		ToUserID:   to.UserID,
+		ToBot:      to.IsBot,
+		Amount:     int64(c.Amount),
+	}
+	what := fmt.Sprintf("room %d: chop %d of game %s", p.RoomID, p.Index, p.GameID)
+
+	e.chopMu.Lock()
+	b := e.chops[p.GameID]
+	if b == nil {
+		b = &chopBooking{}
+		e.chops[p.GameID] = b
+	}
+	prev, done := b.done, make(chan struct{})
+	b.done = done
+	e.chopMu.Unlock()
+
+	go func() {
+		if prev != nil {
+			<-prev
+		}
+		e.retry(what, func(ctx context.Context) error {
+			return e.gold.ApplyChop(ctx, p)
+		}, func(err error) {
+			e.chopMu.Lock()
+			if err != nil {
+				b.failed = append(b.failed, p.Index)
+			} else {
+				b.booked++
+			}
+			e.chopMu.Unlock()
+			close(done)
+		})
+	}()
+}
+
+// waitChops waits until every chop of a finished game has been booked or
+// given up on, and returns how many were booked and the indexes of those
+// that were not.
+func (e *Engine) waitChops(gameID string) (int, []int) {
+	e.chopMu.Lock()
+	b := e.chops[gameID]
+	delete(e.chops, gameID)
+	e.chopMu.Unlock()
+	if b == nil {
+		return 0, nil
+	}
+
+	<-b.done
+	e.chopMu.Lock()
+	defer e.chopMu.Unlock()
+	return b.booked, b.failed
+}
+
+// settleChops lists the game's chops in the settlement and shows each
+// seat's net from them. The gold was paid when the chops were made.
+func settleChops(room *models.Room, settlement *models.Settlement) {
+	settlement.Chops = room.Chops
+	for _, c := range room.Chops {
+		if r := settlement.Results[c.Chopper]; r != nil {
+			r.ChopDelta += c.Amount
+		}
+		if r := settlement.Results[c.Chopped]; r != nil {
+			r.ChopDelta -= c.Amount
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+func TestChopValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		table  string
+		chop   string
+		value  int
+		beaten bool
+	}{
+		{"three pairs on a black 2", "2S", "3S 3C 4S 4C 5S 5C", 100, true},
+		{"four of a kind on a red 2", "2H", "6S 6C 6D 6H", 400, true},
+		{"four pairs on red and black 2s", "2S 2H", "3S 3C 4S 4C 5S 5C 6S 6C", 900, true},
+		{"three pairs do not chop a pair of 2s", "2S 2H", "3S 3C 4S 4C 5S 5C", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := models.NewRoom(1, "test", 100)
+			table := parseCards(t, tt.table)
+			combo, _ := ClassifyCombination(table)
+			room.TablePlay = &models.TablePlay{PlayerIndex: 0, Cards: table, ComboType: combo}
+
+			cards := parseCards(t, tt.chop)
+			playCombo, _ := ClassifyCombination(cards)
+			if got := CanBeat(room.TablePlay, cards, playCombo); got != tt.beaten {
+				t.Fatalf("CanBeat = %v, want %v", got, tt.beaten)
+			}
+			if !tt.beaten {
+				return
+			}
+			chop := findChop(room, 1, cards, playCombo)
+			if chop == nil || chop.Value != tt.value || chop.Chain != 1 {
+				t.Errorf("chop = %+v, want chain 1 worth %d", chop, tt.value)
+			}
+		})
+	}
+}
+
+// Four of a kind outranks three pairs, so it chops a chop made with them and
+// takes over the chain.
+func TestChopOverChop(t *testing.T) {
+	room := models.NewRoom(1, "test", 100)
+	room.TablePlay = &models.TablePlay{PlayerIndex: 0, Cards: parseCards(t, "2S"), ComboType: models.ComboSingle}
+	pairs := parseCards(t, "3S 3C 4S 4C 5S 5C")
+	first := findChop(room, 1, pairs, models.ComboDoubleSequence)
+	chargeChop(room, first)
+	room.TablePlay = &models.TablePlay{PlayerIndex: 1, Cards: pairs, ComboType: models.ComboDoubleSequence}
+
+	quads := parseCards(t, "9S 9C 9D 9H")
+	if !CanBeat(room.TablePlay, quads, models.ComboFourOfAKind) {
+		t.Fatal("four of a kind should beat three pairs")
+	}
+	chop := findChop(room, 0, quads, models.ComboFourOfAKind)
+	if chop == nil || chop.Chopped != 1 || chop.Chain != 2 || chop.Value != 200 {
+		t.Errorf("chop = %+v, want seat 1 chopped for 200 in chain 2", chop)
+	}
+}
@@ -1,84 +1,174 @@
 package game
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/game-playzui/tienlen-server/internal/auth"
+	"github.com/game-playzui/tienlen-server/internal/clock"
 	"github.com/game-playzui/tienlen-server/internal/models"
 	"github.com/game-playzui/tienlen-server/internal/ws"
 )
 
-const TurnTimeout = 30 * time.Second
+const (
+	settlementTimeout    = 5 * time.Second
+	settlementAttempts   = 3
+	settlementResetDelay = 5 * time.Second
+	goldQueryTimeout     = 2 * time.Second
+
+	// maxLossMultiplier is the most antes a single loser can pay in one
+	// Tien Len game (all four 2s held at the end). It sizes the escrow
+	// reserved per player and caps chop penalties.
+	maxLossMultiplier = 4
+)
 
 type MatchRequester interface {
 	RequestMatch(client *ws.Client, anteLevel int)
 }
 
 type Engine struct {
-	hub        *ws.Hub
-	mm         MatchRequester
-	turnTimers map[int]*time.Timer
+	hub   *ws.Hub
+	mm    MatchRequester
+	gold  GoldStore
+	auto  AutoPlayer
+	clock clock.Clock
+	rng   models.Rand
+	sched *scheduler
+
+	// deckRand shuffles a deal from its key; tests swap it to stack decks.
+	deckRand func(key []byte) models.Rand
+
+	history      HistoryStore
+	historyQueue chan historyEvent
+
+	// away maps disconnected players to the room holding their seat.
+	awayMu sync.Mutex
+	away   map[int64]int
+
+	// replays maps users to the replay they are watching.
+	replayMu sync.Mutex
+	replays  map[int64]*replayStream
+	// admins may watch any seat's hand in a replay; set before serving.
+	admins auth.Admins
+
+	// chops tracks the booking of each game's chops until the game is
+	// settled or aborted.
+	chopMu sync.Mutex
+	chops  map[string]*chopBooking
 }
 
-func NewEngine(hub *ws.Hub, mm MatchRequester) *Engine {
+// NewEngine wires the engine into the hub. A nil gold store skips balance
+// checks and persistence, which is only useful for local testing, and a nil
+// history store keeps no game history. A nil auto player makes absent
+// players pass every turn. A nil clock or rng falls back to the wall clock
+// and crypto/rand; tests pass fakes to make timers and deals reproducible.
+func NewEngine(hub *ws.Hub, mm MatchRequester, gold GoldStore, history HistoryStore, auto AutoPlayer, clk clock.Clock, rng models.Rand) *Engine {
+	if clk == nil {
+		clk = clock.Real{}
+	}
+	if rng == nil {
+		rng = models.CryptoRand{}
+	}
 	e := &Engine{
-		hub:        hub,
-		mm:         mm,
-		turnTimers: make(map[int]*time.Timer),
+		hub:      hub,
+		mm:       mm,
+		gold:     gold,
+		auto:     auto,
+		clock:    clk,
+		rng:      rng,
+		sched:    newScheduler(clk),
+		deckRand: newDeckRand,
+		away:     make(map[int64]int),
+		replays:  make(map[int64]*replayStream),
+		chops:    make(map[string]*chopBooking),
+	}
+	if history != nil {
+		e.history = history
+		e.historyQueue = make(chan historyEvent, historyQueueSize)
+		go e.runHistory()
 	}
 	hub.OnMessage = e.HandleMessage
+	hub.OnConnect = e.handleConnect
+	hub.OnDisconnect = e.handleDisconnect
 	return e
 }
 
-func (e *Engine) HandleMessage(client *ws.Client, msg ws.Message) {
+// SetAdmins lets admins watch the hands of games they did not play. It must
+// be called before any client connects.
+func (e *Engine) SetAdmins(admins auth.Admins) {
+	e.admins = admins
+}
+
+// HandleMessage runs on the goroutine of the room the message is about.
+// room is nil for messages outside any room.
+func (e *Engine) HandleMessage(client *ws.Client, room *models.Room, msg ws.Message) {
+	switch msg.Type {
+	case ws.MsgAutoMatch:
+		e.handleAutoMatch(client, msg.Payload)
+		return
+	case ws.MsgWatchReplay:
+		e.handleWatchReplay(client, msg.Payload)
+		return
+	case ws.MsgStopReplay:
+		e.stopReplay(client.UserID)
+		return
+	}
+	if room == nil {
+		if msg.Type == ws.MsgJoinRoom {
+			client.Deliver(ws.NewErrorMessage("room not found"))
+		}
+		return
+	}
+
 	switch msg.Type {
 	case ws.MsgJoinRoom:
-		e.handleJoinRoom(client, msg.Payload)
+		e.handleJoinRoom(client, room, msg.Payload)
 	case ws.MsgLeaveRoom:
-		e.handleLeaveRoom(client)
+		e.handleLeaveRoom(client, room)
 	case ws.MsgReady:
-		e.handleReady(client)
+		e.handleReady(client, room, msg.Payload)
 	case ws.MsgPlayCards:
-		e.handlePlayCards(client, msg.Payload)
+		e.handlePlayCards(client, room, msg.Payload)
 	case ws.MsgPassTurn:
-		e.handlePassTurn(client)
+		e.handlePassTurn(client, room)
 	case ws.MsgChat:
-		e.handleChat(client, msg.Payload)
-	case ws.MsgAutoMatch:
-		e.handleAutoMatch(client, msg.Payload)
+		e.handleChat(client, room, msg.Payload)
+	case ws.MsgResumeControl:
+		e.handleResumeControl(client, room)
+	case ws.MsgDeclareSam:
+		e.handleDeclareSam(client, room, msg.Payload)
 	}
 }
 
-func (e *Engine) handleJoinRoom(client *ws.Client, payload json.RawMessage) {
+func (e *Engine) handleJoinRoom(client *ws.Client, room *models.Room, payload json.RawMessage) {
 	var p ws.JoinRoomPayload
 	if err := json.Unmarshal(payload, &p); err != nil {
-		client.Send <- ws.NewErrorMessage("invalid join_room payload")
+		client.Deliver(ws.NewErrorMessage("invalid join_room payload"))
 		return
 	}
 
-	if currentRoom := client.GetRoom(); currentRoom > 0 {
-		client.Send <- ws.NewErrorMessage("already in a room, leave first")
+	// Claim the room before seating: a join sent to another room at the
+	// same time runs on that room's goroutine, not this one.
+	if !client.CompareAndSetRoom(0, room.ID) {
+		client.Deliver(ws.NewErrorMessage("already in a room, leave first"))
 		return
 	}
-
-	room := e.hub.GetRoom(p.RoomID)
-	if room == nil {
-		client.Send <- ws.NewErrorMessage("room not found")
-		return
-	}
-
-	room.Lock()
+	e.stopReplay(client.UserID)
 
 	if room.Phase != models.PhaseLobby {
 		if room.AddSpectator(&models.Spectator{UserID: client.UserID, Username: client.Username}) {
-			client.SetRoom(p.RoomID)
 			data, _ := ws.NewMessage(ws.MsgRoomUpdate, e.buildRoomState(room, -1))
-			room.Unlock()
-			client.Send <- data
+			client.Deliver(data)
 		} else {
-			room.Unlock()
-			client.Send <- ws.NewErrorMessage("room is full (game in progress)")
+			client.CompareAndSetRoom(room.ID, 0)
+			client.Deliver(ws.NewErrorMessage("room is full (game in progress)"))
 		}
 		return
 	}
@@ -86,13 +176,11 @@ func (e *Engine) handleJoinRoom(client *ws.Client, payload json.RawMessage) {
 	seat := room.FindEmptySeat()
 	if seat < 0 {
 		if room.AddSpectator(&models.Spectator{UserID: client.UserID, Username: client.Username}) {
-			client.SetRoom(p.RoomID)
 			data, _ := ws.NewMessage(ws.MsgRoomUpdate, e.buildRoomState(room, -1))
-			room.Unlock()
-			client.Send <- data
+			client.Deliver(data)
 		} else {
-			room.Unlock()
-			client.Send <- ws.NewErrorMessage("room is full")
+			client.CompareAndSetRoom(room.ID, 0)
+			client.Deliver(ws.NewErrorMessage("room is full"))
 		}
 		return
 	}
@@ -103,126 +191,215 @@ func (e *Engine) handleJoinRoom(client *ws.Client, payload json.RawMessage) {
 		SeatIndex: seat,
 		IsBot:     client.IsBot,
 	}
-	client.SetRoom(p.RoomID)
 
 	if !client.IsBot && room.WaitingSince == nil {
-		now := time.Now()
+		now := e.clock.Now()
 		room.WaitingSince = &now
 	}
+	e.commitDeck(room)
 
 	data, _ := ws.NewMessage(ws.MsgRoomUpdate, room.ToInfo())
-	e.hub.BroadcastToRoomHeld(room, data)
-	room.Unlock()
+	e.hub.Broadcast(room, data)
 }
 
-func (e *Engine) handleLeaveRoom(client *ws.Client) {
-	roomID := client.GetRoom()
-	if roomID == 0 {
+func (e *Engine) handleLeaveRoom(client *ws.Client, room *models.Room) {
+	if client.GetRoom() != room.ID {
 		return
 	}
 	client.SetRoom(0)
-	e.hub.HandlePlayerLeave(client, roomID)
+	e.leaveRoom(room, client.UserID)
 }
 
-func (e *Engine) handleReady(client *ws.Client) {
-	roomID := client.GetRoom()
-	room := e.hub.GetRoom(roomID)
-	if room == nil {
-		return
+// leaveRoom removes the user from the room and cleans up after any game
+// that the departure aborted.
+// Must be called on the room's goroutine.
+func (e *Engine) leaveRoom(room *models.Room, userID int64) {
+	if gameID := e.hub.RemoveFromRoom(room, userID); gameID != "" {
+		e.gameAborted(room.ID, gameID, userID)
 	}
+}
 
-	room.Lock()
+func (e *Engine) gameAborted(roomID int, gameID string, userID int64) {
+	e.cancelTurnTimer(roomID)
+	e.recordAbort(gameID)
+	log.Printf("room %d: game %s aborted, user %d left", roomID, gameID, userID)
+	go func() {
+		// Chops stand when a game is aborted; book them before the escrow
+		// they are taken from is released.
+		_, failed := e.waitChops(gameID)
+		for _, index := range failed {
+			log.Printf("room %d: chop %d of game %s was never booked and needs reconciling", roomID, index, gameID)
+		}
+		e.releaseEscrow(gameID)
+	}()
+}
+
+func (e *Engine) handleReady(client *ws.Client, room *models.Room, payload json.RawMessage) {
+	var p ws.ReadyPayload
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			client.Deliver(ws.NewErrorMessage("invalid ready payload"))
+			return
+		}
+	}
+	if len(p.ClientSeed) > models.MaxClientSeedLen {
+		client.Deliver(ws.NewErrorMessage(fmt.Sprintf("client_seed is longer than %d bytes", models.MaxClientSeedLen)))
+		return
+	}
+	// A client seed only counts if it was chosen after the server's seed
+	// was committed to.
+	if p.ClientSeed != "" && room.DeckSeed == nil {
+		client.Deliver(ws.NewErrorMessage("no seed hash has been published for this deal yet"))
+		return
+	}
 
 	if room.Phase != models.PhaseLobby {
-		room.Unlock()
-		client.Send <- ws.NewErrorMessage("game already in progress")
+		client.Deliver(ws.NewErrorMessage("game already in progress"))
 		return
 	}
 
 	idx, player := room.FindPlayerByUserID(client.UserID)
 	if idx < 0 {
-		room.Unlock()
-		client.Send <- ws.NewErrorMessage("you are not a player in this room")
+		client.Deliver(ws.NewErrorMessage("you are not a player in this room"))
 		return
 	}
 
+	if !player.IsReady && !player.IsBot {
+		if err := e.checkCanCoverAnte(room, client.UserID); err != nil {
+			client.Deliver(ws.NewErrorMessage(err.Error()))
+			return
+		}
+	}
+
 	player.IsReady = !player.IsReady
+	player.ClientSeed = ""
+	if player.IsReady {
+		player.ClientSeed = p.ClientSeed
+	}
 
 	data, _ := ws.NewMessage(ws.MsgRoomUpdate, room.ToInfo())
-	e.hub.BroadcastToRoomHeld(room, data)
+	e.hub.Broadcast(room, data)
 
 	if room.AllPlayersReady() {
 		e.startGame(room)
 	}
-
-	room.Unlock()
 }
 
 func (e *Engine) startGame(room *models.Room) {
+	room.GameID = newGameID()
+	if !e.reserveEscrow(room) {
+		return
+	}
+
 	room.Phase = models.PhaseDealing
 	room.WaitingSince = nil
-	hands := models.DealCards()
-
-	firstPlayer := -1
-	threeSpades := models.ThreeOfSpades()
+	rules := RulesFor(room)
+	hands := e.dealDeck(room, rules.HandSize())
 
-	for i := 0; i < 4; i++ {
+	for i := 0; i < room.Seats; i++ {
 		room.Players[i].Hand = hands[i]
-		room.Players[i].CardCount = 13
+		room.Players[i].CardCount = rules.HandSize()
 		room.Players[i].IsReady = false
-		if models.ContainsCard(hands[i], threeSpades) {
-			firstPlayer = i
-		}
+		room.Players[i].ClientSeed = ""
+		room.Players[i].TimeBank = time.Duration(room.TimeBank) * time.Second
+	}
+
+	// The previous winner leads. Without one still at the table this is an
+	// opening game: the holder of the opening card leads and must play it.
+	firstPlayer, _ := room.FindPlayerByUserID(room.LastWinnerID)
+	room.OpeningCard = nil
+	if firstPlayer < 0 {
+		var card models.Card
+		firstPlayer, card = openingLead(rules, hands)
+		room.OpeningCard = &card
 	}
 
 	room.CurrentTurn = firstPlayer
-	room.TablePlay = nil
-	room.PassCount = 0
+	room.ClearRound()
+	room.FinishOrder = nil
+	room.Chops = nil
+	room.SamDeclarer = -1
 	room.Winner = -1
 	room.Phase = models.PhasePlaying
+	if room.Rules.Game == models.GameSamLoc {
+		room.Phase = models.PhaseDeclaring
+	} else {
+		// Arm the clock first so the deal carries the turn deadline.
+		e.startTurnTimer(room)
+	}
+	e.recordDeal(room, firstPlayer)
 
-	for i := 0; i < 4; i++ {
+	for i := 0; i < room.Seats; i++ {
 		p := room.Players[i]
-		state := e.buildGameStateForPlayer(room, i)
+		state := buildGameStateForPlayer(room, i)
 		data, _ := ws.NewMessage(ws.MsgCardDealt, state)
 		e.hub.SendToClient(p.UserID, data)
 	}
 
 	for _, s := range room.Spectators {
-		state := e.buildGameStateForPlayer(room, -1)
+		state := buildGameStateForPlayer(room, -1)
 		data, _ := ws.NewMessage(ws.MsgGameState, state)
 		e.hub.SendToClient(s.UserID, data)
 	}
 
-	e.startTurnTimer(room)
+	if seat, kind, ok := FindInstantWin(room, firstPlayer); ok {
+		e.endInstantWin(room, seat, kind)
+		return
+	}
+
 	log.Printf("game started in room %d, first player: seat %d", room.ID, firstPlayer)
+	if room.Phase == models.PhaseDeclaring {
+		e.startDeclaring(room)
+	}
 }
 
-func (e *Engine) handlePlayCards(client *ws.Client, payload json.RawMessage) {
-	var p ws.PlayCardsPayload
-	if err := json.Unmarshal(payload, &p); err != nil {
-		client.Send <- ws.NewErrorMessage("invalid play_cards payload")
-		return
+func newDeckRand(key []byte) models.Rand {
+	return models.NewDeckRand(key)
+}
+
+// commitDeck draws the server seed for the room's next deal, unless one is
+// already committed, so its hash goes out with the room before anyone
+// readies.
+// Must be called on the room's goroutine.
+func (e *Engine) commitDeck(room *models.Room) {
+	if room.DeckSeed == nil {
+		room.DeckSeed = models.NewDeckSeed(e.rng)
 	}
+}
 
-	roomID := client.GetRoom()
-	room := e.hub.GetRoom(roomID)
-	if room == nil {
+// dealDeck deals from the committed seed mixed with the seats' seeds and
+// keeps the proof for the settlement. It commits to the next deal's seed
+// straight away, so however the game ends the room is back in the lobby
+// with a hash already published.
+// Must be called on the room's goroutine.
+func (e *Engine) dealDeck(room *models.Room, size int) [][]models.Card {
+	e.commitDeck(room)
+	seeds := make([]string, room.Seats)
+	for i := range seeds {
+		seeds[i] = room.Players[i].ClientSeed
+	}
+	room.Deck = models.NewDeckProof(room.DeckSeed, seeds)
+	key := models.DeckKey(room.DeckSeed, seeds)
+	room.DeckSeed = nil
+	e.commitDeck(room)
+	return models.DealCards(e.deckRand(key), room.Seats, size)
+}
+
+func (e *Engine) handlePlayCards(client *ws.Client, room *models.Room, payload json.RawMessage) {
+	var p ws.PlayCardsPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		client.Deliver(ws.NewErrorMessage("invalid play_cards payload"))
 		return
 	}
 
-	room.Lock()
-
 	if room.Phase != models.PhasePlaying {
-		room.Unlock()
-		client.Send <- ws.NewErrorMessage("game is not in playing phase")
+		client.Deliver(ws.NewErrorMessage("game is not in playing phase"))
 		return
 	}
 
 	idx, player := room.FindPlayerByUserID(client.UserID)
 	if idx < 0 || idx != room.CurrentTurn {
-		room.Unlock()
-		client.Send <- ws.NewErrorMessage("not your turn")
+		client.Deliver(ws.NewErrorMessage("not your turn"))
 		return
 	}
 
@@ -230,37 +407,36 @@ func (e *Engine) handlePlayCards(client *ws.Client, payload json.RawMessage) {
 	for i, cp := range p.Cards {
 		rank, err := models.ParseRank(cp.Rank)
 		if err != nil {
-			room.Unlock()
-			client.Send <- ws.NewErrorMessage("invalid card rank: " + cp.Rank)
+			client.Deliver(ws.NewErrorMessage("invalid card rank: " + cp.Rank))
 			return
 		}
 		suit, err := models.ParseSuit(cp.Suit)
 		if err != nil {
-			room.Unlock()
-			client.Send <- ws.NewErrorMessage("invalid card suit: " + cp.Suit)
+			client.Deliver(ws.NewErrorMessage("invalid card suit: " + cp.Suit))
 			return
 		}
 		cards[i] = models.Card{Rank: rank, Suit: suit}
 	}
 
-	if !PlayerOwnsCards(player.Hand, cards) {
-		room.Unlock()
-		client.Send <- ws.NewErrorMessage("you don't have those cards")
+	e.playerActed(player)
+	if err := e.playCards(room, idx, cards); err != nil {
+		client.Deliver(ws.NewErrorMessage(err.Error()))
 		return
 	}
+}
 
-	comboType, valid := ClassifyCombination(cards)
-	if !valid {
-		room.Unlock()
-		client.Send <- ws.NewErrorMessage("invalid card combination")
-		return
+// playCards validates a play for the seat whose turn it is and applies it.
+// The returned error is suitable for showing to the player.
+// Must be called on the room's goroutine.
+func (e *Engine) playCards(room *models.Room, idx int, cards []models.Card) error {
+	player := room.Players[idx]
+	comboType, err := validatePlay(room, idx, cards)
+	if err != nil {
+		return err
 	}
 
-	if !CanBeat(room.TablePlay, cards, comboType) {
-		room.Unlock()
-		client.Send <- ws.NewErrorMessage("your cards cannot beat the current play")
-		return
-	}
+	chop := findChop(room, idx, cards, comboType)
+	room.OpeningCard = nil
 
 	player.Hand = models.RemoveCards(player.Hand, cards)
 	player.CardCount = len(player.Hand)
@@ -270,236 +446,288 @@ func (e *Engine) handlePlayCards(client *ws.Client, payload json.RawMessage) {
 		Cards:       cards,
 		ComboType:   comboType,
 	}
-	room.PassCount = 0
 
-	e.cancelTurnTimer(room.ID)
+	e.stopTurnClock(room)
+	e.recordMove(room, idx, models.MovePlay, cards)
 
 	moveData, _ := ws.NewMessage(ws.MsgMovePlayed, map[string]interface{}{
 		"player_index": idx,
 		"cards":        cards,
 		"combo_type":   comboType,
 	})
-	e.hub.BroadcastToRoomHeld(room, moveData)
+	e.hub.Broadcast(room, moveData)
+
+	room.LastChop = nil
+	if chop != nil {
+		e.applyChop(room, chop)
+	}
+
+	// A sâm ends as soon as anyone beats the declarer or they go out.
+	if room.SamDeclarer >= 0 && (idx != room.SamDeclarer || player.CardCount == 0) {
+		e.endSam(room, idx)
+		return nil
+	}
 
 	if player.CardCount == 0 {
-		e.endGame(room)
-		room.Unlock()
-		return
+		if !room.Rules.FullRanking || room.Rules.Game == models.GameSamLoc {
+			e.endGame(room)
+			return nil
+		}
+		if e.playerFinished(room, idx) {
+			return nil
+		}
 	}
 
 	e.advanceTurn(room)
-	room.Unlock()
+	return nil
 }
 
-func (e *Engine) handlePassTurn(client *ws.Client) {
-	roomID := client.GetRoom()
-	room := e.hub.GetRoom(roomID)
-	if room == nil {
-		return
+// validatePlay checks that seat idx may make a play under the room's rule
+// set and returns its combination. The error is suitable for showing to the
+// player.
+func validatePlay(room *models.Room, idx int, cards []models.Card) (models.CombinationType, error) {
+	rules := RulesFor(room)
+
+	if !PlayerOwnsCards(room.Players[idx].Hand, cards) {
+		return 0, errors.New("you don't have those cards")
+	}
+
+	if opening := room.OpeningCard; opening != nil && !models.ContainsCard(cards, *opening) {
+		return 0, fmt.Errorf("the first play must include the %s of %s", opening.Rank, strings.ToLower(opening.Suit.Name()))
+	}
+
+	comboType, valid := rules.Classify(cards)
+	if !valid {
+		return 0, errors.New("invalid card combination")
 	}
 
-	room.Lock()
+	if !rules.CanBeat(room.TablePlay, cards, comboType) {
+		return 0, errors.New("your cards cannot beat the current play")
+	}
+	return comboType, nil
+}
 
+func (e *Engine) handlePassTurn(client *ws.Client, room *models.Room) {
 	if room.Phase != models.PhasePlaying {
-		room.Unlock()
 		return
 	}
 
-	idx, _ := room.FindPlayerByUserID(client.UserID)
+	idx, player := room.FindPlayerByUserID(client.UserID)
 	if idx < 0 || idx != room.CurrentTurn {
-		room.Unlock()
-		client.Send <- ws.NewErrorMessage("not your turn")
+		client.Deliver(ws.NewErrorMessage("not your turn"))
 		return
 	}
 
 	if room.TablePlay == nil {
-		room.Unlock()
-		client.Send <- ws.NewErrorMessage("you must play cards to start the round")
+		client.Deliver(ws.NewErrorMessage("you must play cards to start the round"))
 		return
 	}
 	if room.TablePlay.PlayerIndex == idx {
-		room.Unlock()
-		client.Send <- ws.NewErrorMessage("you cannot pass on your own play")
+		client.Deliver(ws.NewErrorMessage("you cannot pass on your own play"))
 		return
 	}
 
-	e.cancelTurnTimer(room.ID)
-	room.PassCount++
-
-	passData, _ := ws.NewMessage(ws.MsgTurnChange, map[string]interface{}{
-		"action":       "pass",
-		"player_index": idx,
-	})
-	e.hub.BroadcastToRoomHeld(room, passData)
+	e.playerActed(player)
+	e.passTurn(room, idx, models.MovePass)
+}
 
-	if room.PassCount >= 3 {
-		room.TablePlay = nil
-		room.PassCount = 0
+// playerFinished records a player going out in a full-ranking game and
+// settles the game once it is over. Reports whether the game ended.
+// Must be called on the room's goroutine.
+func (e *Engine) playerFinished(room *models.Room, idx int) bool {
+	log.Printf("room %d: seat %d finished in place %d", room.ID, idx, len(room.FinishOrder)+1)
+	over := finishPlace(room, idx)
+	if over {
+		e.endRankedGame(room)
 	}
-
-	e.advanceTurn(room)
-	room.Unlock()
+	return over
 }
 
-// advanceTurn must be called while room lock is held
-func (e *Engine) advanceTurn(room *models.Room) {
-	next := (room.CurrentTurn + 1) % 4
+// finishPlace gives seat idx the next place in a full-ranking game. Once
+// only one player still holds cards they take last place and the game is
+// over. Reports whether it is.
+func finishPlace(room *models.Room, idx int) bool {
+	room.FinishOrder = append(room.FinishOrder, idx)
 
-	attempts := 0
-	for attempts < 4 {
-		if room.Players[next] != nil && room.Players[next].CardCount > 0 {
-			break
+	remaining := -1
+	for i, p := range room.Players {
+		if p != nil && p.CardCount > 0 {
+			if remaining >= 0 {
+				return false
+			}
+			remaining = i
 		}
-		next = (next + 1) % 4
-		attempts++
 	}
-
-	if room.TablePlay != nil && room.TablePlay.PlayerIndex == next {
-		room.TablePlay = nil
-		room.PassCount = 0
+	if remaining >= 0 {
+		room.FinishOrder = append(room.FinishOrder, remaining)
 	}
+	return true
+}
 
-	room.CurrentTurn = next
+// passTurn records a pass (or timeout) for the seat whose turn it is. The
+// seat is locked out until the round clears.
+// Must be called on the room's goroutine.
+func (e *Engine) passTurn(room *models.Room, idx int, action models.MoveAction) {
+	e.stopTurnClock(room)
+	e.recordMove(room, idx, action, nil)
+	room.PassCount++
+	room.RoundPassed[idx] = true
 
-	turnData, _ := ws.NewMessage(ws.MsgTurnChange, map[string]interface{}{
-		"current_turn": next,
-		"table_clear":  room.TablePlay == nil,
-	})
-	e.hub.BroadcastToRoomHeld(room, turnData)
+	e.handBackControl(room)
+	nextTurn(room)
+	e.startTurnTimer(room)
+	payload := turnPayload(room, false)
+	payload["action"] = action
+	payload["player_index"] = idx
+	data, _ := ws.NewMessage(ws.MsgTurnChange, payload)
+	e.hub.Broadcast(room, data)
+}
 
+// advanceTurn moves the turn on, starts its timer and tells the table.
+// Must be called on the room's goroutine.
+func (e *Engine) advanceTurn(room *models.Room) {
+	e.handBackControl(room)
+	nextTurn(room)
 	e.startTurnTimer(room)
+	e.broadcastTurn(room, false)
 }
 
-// countTwos returns how many 2s are in the hand.
-func countTwos(hand []models.Card) int {
-	count := 0
-	for _, c := range hand {
-		if c.Rank == models.Two {
-			count++
+// nextTurn gives the turn to the next seat that still has cards and has
+// not passed this round. Once the turn comes back around to the player who
+// made the table play, everyone else has passed and that player leads a new
+// round.
+//
+// In full-ranking games the table play may belong to a player who has
+// already gone out. When every remaining player has passed on it, the round
+// clears and the next player after them leads.
+func nextTurn(room *models.Room) {
+	next := nextActiveSeat(room, room.CurrentTurn, true)
+
+	if room.TablePlay != nil {
+		owner := room.TablePlay.PlayerIndex
+		if next == owner {
+			room.ClearRound()
+		} else if next < 0 {
+			room.ClearRound()
+			next = nextActiveSeat(room, owner, false)
 		}
 	}
-	return count
+
+	room.CurrentTurn = next
 }
 
-// deadPigMultiplier returns the penalty multiplier for a loser.
-// Extended rules: holding any 2 = 2x, 13 cards (never played) = 3x, all four 2s = 4x.
-// Highest applicable multiplier wins (they don't stack).
-func deadPigMultiplier(hand []models.Card, cardCount int) int {
-	twos := countTwos(hand)
-	if twos == 4 {
-		return 4
+// broadcastTurn tells the table whose turn it is and when it runs out.
+// timeBank marks the seat on turn starting on their time bank.
+// Must be called on the room's goroutine.
+func (e *Engine) broadcastTurn(room *models.Room, timeBank bool) {
+	data, _ := ws.NewMessage(ws.MsgTurnChange, turnPayload(room, timeBank))
+	e.hub.Broadcast(room, data)
+}
+
+// turnPayload is the turn_change for the seat on turn. Replays have no turn
+// clock and leave the deadline out.
+func turnPayload(room *models.Room, timeBank bool) map[string]interface{} {
+	payload := map[string]interface{}{
+		"current_turn": room.CurrentTurn,
+		"table_clear":  room.TablePlay == nil,
 	}
-	if cardCount == 13 {
-		return 3
+	if !room.TurnDeadline.IsZero() {
+		payload["deadline"] = room.TurnDeadline.UnixMilli()
 	}
-	if twos > 0 {
-		return 2
+	if room.OpeningCard != nil {
+		payload["opening_card"] = room.OpeningCard
 	}
-	return 1
-}
-
-// endGame must be called while room lock is held
-func (e *Engine) endGame(room *models.Room) {
-	winnerIdx := -1
-	for i, p := range room.Players {
-		if p != nil && p.CardCount == 0 {
-			winnerIdx = i
-			break
-		}
+	if timeBank {
+		payload["time_bank"] = true
 	}
+	return payload
+}
 
-	e.cancelTurnTimer(room.ID)
-	room.Phase = models.PhaseSettlement
-	room.Winner = winnerIdx
-
-	ante := room.AnteAmount
-	settlement := make(map[string]interface{})
-	results := make([]map[string]interface{}, 4)
-
-	totalPot := 0
-	for i := 0; i < 4; i++ {
-		p := room.Players[i]
-		if p == nil {
+// nextActiveSeat returns the first seat after from that still holds cards,
+// optionally skipping seats locked out of the round, or -1 if there is none.
+func nextActiveSeat(room *models.Room, from int, skipPassed bool) int {
+	for i := 1; i <= room.Seats; i++ {
+		seat := (from + i) % room.Seats
+		p := room.Players[seat]
+		if p == nil || p.CardCount == 0 {
 			continue
 		}
-		if i == winnerIdx {
+		if skipPassed && room.RoundPassed[seat] {
 			continue
 		}
-		multiplier := deadPigMultiplier(p.Hand, p.CardCount)
-		loserPays := ante * multiplier
-		totalPot += loserPays
-
-		results[i] = map[string]interface{}{
-			"seat":               i,
-			"user_id":            p.UserID,
-			"username":           p.Username,
-			"cards_left":         p.CardCount,
-			"twos_held":          countTwos(p.Hand),
-			"penalty_multiplier": multiplier,
-			"gold_delta":         -loserPays,
-			"is_bot":             p.IsBot,
-		}
+		return seat
 	}
+	return -1
+}
 
-	serverFee := totalPot / 10
-	winnerReceives := totalPot - serverFee
-
-	if wp := room.Players[winnerIdx]; wp != nil {
-		results[winnerIdx] = map[string]interface{}{
-			"seat":               winnerIdx,
-			"user_id":            wp.UserID,
-			"username":           wp.Username,
-			"cards_left":         0,
-			"twos_held":          0,
-			"penalty_multiplier": 0,
-			"gold_delta":         winnerReceives,
-			"is_bot":             wp.IsBot,
+// openingLead finds who leads a table's opening game: the holder of the rule
+// set's opening card or, if it was left undealt at a short table, of the
+// lowest card dealt. It returns that seat and the card their first play must
+// include.
+func openingLead(rules RuleSet, hands [][]models.Card) (int, models.Card) {
+	want := rules.OpeningCard()
+	seat, lowest := -1, models.Card{}
+	for i, hand := range hands {
+		if models.ContainsCard(hand, want) {
+			return i, want
+		}
+		for _, c := range hand {
+			if seat < 0 || c.Value() < lowest.Value() {
+				seat, lowest = i, c
+			}
 		}
 	}
+	return seat, lowest
+}
 
-	settlement["winner"] = winnerIdx
-	settlement["results"] = results
-	settlement["server_fee"] = serverFee
-	settlement["total_pot"] = totalPot
-
-	data, _ := ws.NewMessage(ws.MsgSettlement, settlement)
-	e.hub.BroadcastToRoomHeld(room, data)
-
-	log.Printf("room %d settlement: winner=seat%d pot=%d fee=%d payout=%d",
-		room.ID, winnerIdx, totalPot, serverFee, winnerReceives)
-
-	go func(roomID int) {
-		time.Sleep(5 * time.Second)
-		r := e.hub.GetRoom(roomID)
-		if r == nil {
-			return
+// resetRoom returns a finished room to the lobby. Players still
+// disconnected lose their seat now that their game is over.
+// Must be called on the room's goroutine.
+func (e *Engine) resetRoom(r *models.Room) {
+	r.Phase = models.PhaseLobby
+	r.ClearRound()
+	r.FinishOrder = nil
+	r.Chops = nil
+	r.SamDeclarer = -1
+	r.SamPending = [4]bool{}
+	r.TurnDeadline = time.Time{}
+	r.BankSince = time.Time{}
+	r.Winner = -1
+	for i, p := range r.Players {
+		if p != nil && p.Disconnected {
+			r.Players[i] = nil
+			e.clearAway(p.UserID, r.ID)
+			continue
 		}
-		r.Lock()
-		r.Phase = models.PhaseLobby
-		r.TablePlay = nil
-		r.PassCount = 0
-		r.Winner = -1
-		for _, p := range r.Players {
-			if p != nil {
-				p.Hand = nil
-				p.CardCount = 0
-				p.IsReady = false
-			}
+		if p != nil {
+			p.Hand = nil
+			p.CardCount = 0
+			p.IsReady = false
+			p.AutoPlay = false
+			p.ResumePending = false
+			p.MissedTurns = 0
 		}
-		resetData, _ := ws.NewMessage(ws.MsgRoomUpdate, r.ToInfo())
-		e.hub.BroadcastToRoomHeld(r, resetData)
-		r.Unlock()
-	}(room.ID)
+	}
+	e.commitDeck(r)
+	resetData, _ := ws.NewMessage(ws.MsgRoomUpdate, r.ToInfo())
+	e.hub.Broadcast(r, resetData)
 }
 
-func (e *Engine) handleChat(client *ws.Client, payload json.RawMessage) {
+func newGameID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b[:])
+}
+
+func (e *Engine) handleChat(client *ws.Client, room *models.Room, payload json.RawMessage) {
 	var p ws.ChatPayload
 	if err := json.Unmarshal(payload, &p); err != nil {
 		return
 	}
 
-	roomID := client.GetRoom()
-	if roomID == 0 {
+	if client.GetRoom() != room.ID {
 		return
 	}
 
@@ -507,14 +735,13 @@ func (e *Engine) handleChat(client *ws.Client, payload json.RawMessage) {
 		Message: p.Message,
 		Sender:  client.Username,
 	})
-	// Chat doesn't hold room lock, safe to use regular broadcast
-	e.hub.BroadcastToRoom(roomID, data)
+	e.hub.Broadcast(room, data)
 }
 
 func (e *Engine) handleAutoMatch(client *ws.Client, payload json.RawMessage) {
 	var p ws.AutoMatchPayload
 	if err := json.Unmarshal(payload, &p); err != nil {
-		client.Send <- ws.NewErrorMessage("invalid auto_match payload")
+		client.Deliver(ws.NewErrorMessage("invalid auto_match payload"))
 		return
 	}
 	if e.mm != nil {
@@ -522,47 +749,89 @@ func (e *Engine) handleAutoMatch(client *ws.Client, payload json.RawMessage) {
 	}
 }
 
+// startTurnTimer arms the timer for the current turn. Seats being
+// auto-played move after a short delay; everyone else gets the room's turn
+// timer.
+// Must be called on the room's goroutine.
 func (e *Engine) startTurnTimer(room *models.Room) {
-	e.cancelTurnTimer(room.ID)
+	e.stopTurnClock(room)
 
+	timeout := time.Duration(room.TurnTimer) * time.Second
+	if room.TurnTimer <= 0 {
+		timeout = models.DefaultTurnTimer * time.Second
+	}
+	if p := room.Players[room.CurrentTurn]; p != nil && p.AutoPlay {
+		timeout = autoPlayDelay
+	}
+	e.armTurnTimer(room, timeout)
+}
+
+// armTurnTimer sets the current turn's deadline timeout from now; once it
+// passes, turnExpired runs on the room's goroutine.
+func (e *Engine) armTurnTimer(room *models.Room, timeout time.Duration) {
 	roomID := room.ID
 	turnSeat := room.CurrentTurn
+	room.TurnDeadline = e.clock.Now().Add(timeout)
 
-	timer := time.AfterFunc(TurnTimeout, func() {
-		r := e.hub.GetRoom(roomID)
-		if r == nil {
-			return
-		}
-		r.Lock()
-
-		if r.Phase != models.PhasePlaying || r.CurrentTurn != turnSeat {
-			r.Unlock()
-			return
-		}
-
-		r.PassCount++
-		timeoutData, _ := ws.NewMessage(ws.MsgTurnChange, map[string]interface{}{
-			"action":       "timeout",
-			"player_index": turnSeat,
+	e.sched.schedule(roomID, timeout, func(gen uint64) {
+		e.hub.Do(roomID, func(r *models.Room) {
+			e.turnExpired(r, turnSeat, gen)
 		})
-		e.hub.BroadcastToRoomHeld(r, timeoutData)
+	})
+}
+
+// turnExpired handles the end of a turn's deadline: a seat being auto-played
+// moves, a seat with time bank left starts drawing on it, and anyone else is
+// passed for.
+// Must be called on the room's goroutine.
+func (e *Engine) turnExpired(room *models.Room, turnSeat int, gen uint64) {
+	if !e.sched.fired(room.ID, gen) || room.Phase != models.PhasePlaying || room.CurrentTurn != turnSeat {
+		return
+	}
 
-		if r.PassCount >= 3 {
-			r.TablePlay = nil
-			r.PassCount = 0
+	p := room.Players[turnSeat]
+	if p != nil && p.AutoPlay {
+		e.autoMove(room, turnSeat)
+		return
+	}
+	if p != nil && !p.IsBot && p.TimeBank > 0 && room.BankSince.IsZero() {
+		room.BankSince = e.clock.Now()
+		e.armTurnTimer(room, p.TimeBank)
+		e.broadcastTurn(room, true)
+		return
+	}
+	if p != nil && !p.IsBot {
+		p.MissedTurns++
+		if p.MissedTurns >= afkTurnsBeforeTakeover {
+			e.setAutoPlay(room, p, true, "afk")
 		}
-		e.advanceTurn(r)
-		r.Unlock()
-	})
+	}
+	// A seat cannot pass on an empty table, so a timed-out leader
+	// plays their lowest card.
+	if room.TablePlay == nil && e.leadLowest(room, turnSeat) {
+		return
+	}
+	e.passTurn(room, turnSeat, models.MoveTimeout)
+}
 
-	e.turnTimers[room.ID] = timer
+// stopTurnClock cancels the turn timer and charges the seat on turn for any
+// time bank they used.
+// Must be called on the room's goroutine.
+func (e *Engine) stopTurnClock(room *models.Room) {
+	e.cancelTurnTimer(room.ID)
+	if room.BankSince.IsZero() {
+		return
+	}
+	if p := room.Players[room.CurrentTurn]; p != nil {
+		p.TimeBank = max(p.TimeBank-e.clock.Now().Sub(room.BankSince), 0)
+	}
+	room.BankSince = time.Time{}
 }
 
+// cancelTurnTimer drops the room's pending deadline, whether a turn or the
+// báo sâm window.
 func (e *Engine) cancelTurnTimer(roomID int) {
-	if timer, ok := e.turnTimers[roomID]; ok {
-		timer.Stop()
-		delete(e.turnTimers, roomID)
-	}
+	e.sched.cancel(roomID)
 }
 
 type GameStatePayload struct {
@@ -572,7 +841,22 @@ type GameStatePayload struct {
 	Hand        []models.Card     `json:"hand,omitempty"`
 	Players     []PlayerInfo      `json:"players"`
 	TablePlay   *models.TablePlay `json:"table_play"`
+	Passed      []int             `json:"passed"`
+	FinishOrder []int             `json:"finish_order,omitempty"`
 	AnteAmount  int               `json:"ante_amount"`
+	Seats       int               `json:"seats"`
+	OpeningCard *models.Card      `json:"opening_card,omitempty"`
+	SamDeclarer *int              `json:"sam_declarer,omitempty"`
+	// Deadline is when the current turn runs out, in Unix milliseconds.
+	Deadline  int64           `json:"deadline,omitempty"`
+	TurnTimer int             `json:"turn_timer"`
+	Variant   models.Variant  `json:"variant"`
+	Game      models.GameType `json:"game"`
+	// ReplayOf is set when the state comes from a replay of that game.
+	ReplayOf string `json:"replay_of,omitempty"`
+	// SeedHash is the commitment to the server seed the hands were dealt
+	// from; the seed itself is revealed in the settlement.
+	SeedHash string `json:"seed_hash,omitempty"`
 }
 
 type PlayerInfo struct {
@@ -582,16 +866,33 @@ type PlayerInfo struct {
 	SeatIndex int    `json:"seat_index"`
 	IsReady   bool   `json:"is_ready"`
 	IsBot     bool   `json:"is_bot"`
+	Connected bool   `json:"connected"`
+	AutoPlay  bool   `json:"auto_play"`
+	// TimeBankMs is the player's remaining time bank in milliseconds.
+	TimeBankMs int64 `json:"time_bank_ms"`
 }
 
-func (e *Engine) buildGameStateForPlayer(room *models.Room, seatIdx int) GameStatePayload {
+func buildGameStateForPlayer(room *models.Room, seatIdx int) GameStatePayload {
 	state := GameStatePayload{
 		RoomID:      room.ID,
 		Phase:       room.Phase,
 		CurrentTurn: room.CurrentTurn,
 		TablePlay:   room.TablePlay,
+		Passed:      room.PassedSeats(),
+		FinishOrder: room.FinishOrder,
 		AnteAmount:  room.AnteAmount,
-		Players:     make([]PlayerInfo, 0, 4),
+		OpeningCard: room.OpeningCard,
+		Game:        room.Rules.Game,
+		Variant:     room.Rules.Variant,
+		Seats:       room.Seats,
+		TurnTimer:   room.TurnTimer,
+		Players:     make([]PlayerInfo, 0, room.Seats),
+	}
+	if room.Phase == models.PhasePlaying && !room.TurnDeadline.IsZero() {
+		state.Deadline = room.TurnDeadline.UnixMilli()
+	}
+	if room.Deck != nil && room.Phase != models.PhaseLobby {
+		state.SeedHash = room.Deck.SeedHash
 	}
 
 	for _, p := range room.Players {
@@ -599,16 +900,24 @@ func (e *Engine) buildGameStateForPlayer(room *models.Room, seatIdx int) GameSta
 			continue
 		}
 		state.Players = append(state.Players, PlayerInfo{
-			UserID:    p.UserID,
-			Username:  p.Username,
-			CardCount: p.CardCount,
-			SeatIndex: p.SeatIndex,
-			IsReady:   p.IsReady,
-			IsBot:     p.IsBot,
+			UserID:     p.UserID,
+			Username:   p.Username,
+			CardCount:  p.CardCount,
+			SeatIndex:  p.SeatIndex,
+			IsReady:    p.IsReady,
+			IsBot:      p.IsBot,
+			Connected:  !p.Disconnected,
+			AutoPlay:   p.AutoPlay,
+			TimeBankMs: p.TimeBank.Milliseconds(),
 		})
 	}
 
-	if seatIdx >= 0 && seatIdx < 4 && room.Players[seatIdx] != nil {
+	if room.SamDeclarer >= 0 {
+		declarer := room.SamDeclarer
+		state.SamDeclarer = &declarer
+	}
+
+	if seatIdx >= 0 && seatIdx < room.Seats && room.Players[seatIdx] != nil {
 		state.Hand = room.Players[seatIdx].Hand
 	}
 
@@ -616,5 +925,5 @@ func (e *Engine) buildGameStateForPlayer(room *models.Room, seatIdx int) GameSta
 }
 
 func (e *Engine) buildRoomState(room *models.Room, seatIdx int) GameStatePayload {
-	return e.buildGameStateForPlayer(room, seatIdx)
+	return buildGameStateForPlayer(room, seatIdx)
 }
@@ -0,0 +1,580 @@
+package game
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/game-playzui/tienlen-server/internal/clock"
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/ws"
+)
+
+// The scenario harness runs a real Hub and Engine in process against fake
+// clients and a fake clock, driven by a small line-based script:
+//
+//	table seats=2 timer=30 bank=30 variant=mien_bac game=sam_loc ranking
+//	deal 3S 4C ... | 4S 5S ...   fixed hands in seat order, or none for a seeded deal
+//	play 0 3S                    seat 0 plays cards
+//	pass 1                       seat 1 passes
+//	leave 1                      seat 1 leaves the table
+//	drop 1                       seat 1's connection drops
+//	reconnect 1                  seat 1 connects again
+//	send 0 declare_sam {"declare":true}
+//	timeout                      run the clock to the current turn deadline
+//	wait 5s                      run the clock forward
+//	watch                        a spectator joins once the game is on; they are s0, s1, ...
+//	see 0 turn_change {"current_turn":1}
+//	quiet *                      nothing more has arrived
+//	drain 0,1                    drop whatever has arrived
+//
+// Seats are named by number and spectators s0, s1 and so on; "*" is every
+// seat and spectator at the table and a comma joins names. see takes the
+// next message each named client received, checks its type and, if given,
+// that its payload contains the JSON: objects may have extra keys, arrays
+// must match element by element and null means absent. Lines starting with
+// # are comments. The fake clock starts at the Unix epoch, so deadlines read
+// as milliseconds since the script began.
+
+// harnessRoomID is the table every scenario plays at.
+const harnessRoomID = 1
+
+// messageWait is how long see waits for a message that is not there yet,
+// such as a settlement booked on another goroutine.
+const messageWait = time.Second
+
+type harness struct {
+	t      *testing.T
+	hub    *ws.Hub
+	engine *Engine
+	clock  *clock.Fake
+	deck   *stackedRand
+
+	seats   []*ws.Client
+	specs   []*ws.Client
+	inbox   map[*ws.Client][]ws.Message
+	started bool
+
+	line int
+	text string
+}
+
+// newHarness sets up a table whose engine records history in history, if
+// it is not nil.
+func newHarness(t *testing.T, history HistoryStore) *harness {
+	hub := ws.NewHub()
+	h := &harness{
+		t:     t,
+		hub:   hub,
+		clock: clock.NewFake(time.Unix(0, 0)),
+		deck:  &stackedRand{},
+		inbox: make(map[*ws.Client][]ws.Message),
+	}
+	h.engine = NewEngine(hub, nil, nil, history, nil, h.clock, models.NewSeededRand(1))
+	h.engine.deckRand = func(key []byte) models.Rand {
+		h.deck.fallback = models.NewDeckRand(key)
+		return h.deck
+	}
+	for i := 0; i < models.MaxSeats; i++ {
+		h.seats = append(h.seats, h.newClient(int64(100+i), fmt.Sprintf("p%d", i)))
+	}
+	return h
+}
+
+// runScenario plays script against a fresh table.
+func runScenario(t *testing.T, script string) {
+	t.Helper()
+	newHarness(t, nil).run(script)
+}
+
+func (h *harness) run(script string) {
+	h.t.Helper()
+	sc := bufio.NewScanner(strings.NewReader(script))
+	for sc.Scan() {
+		h.line++
+		h.text = strings.TrimSpace(sc.Text())
+		if h.text == "" || strings.HasPrefix(h.text, "#") {
+			continue
+		}
+		h.exec(strings.Fields(h.text))
+	}
+}
+
+func (h *harness) newClient(userID int64, name string) *ws.Client {
+	c := ws.NewBotClient(h.hub, userID, name)
+	c.IsBot = false
+	h.hub.RegisterBotClient(c)
+	return c
+}
+
+func (h *harness) fatalf(format string, args ...interface{}) {
+	h.t.Helper()
+	h.t.Fatalf("line %d %q: %s", h.line, h.text, fmt.Sprintf(format, args...))
+}
+
+func (h *harness) exec(f []string) {
+	h.t.Helper()
+	op, args := f[0], f[1:]
+	switch op {
+	case "table":
+		h.table(args)
+	case "deal":
+		h.deal(strings.Join(args, " "))
+	case "play":
+		h.need(args, 2)
+		h.send(h.seat(args[0]), ws.MsgPlayCards, playPayload(parseCards(h.t, strings.Join(args[1:], " "))))
+	case "pass":
+		h.need(args, 1)
+		h.send(h.seat(args[0]), ws.MsgPassTurn, nil)
+	case "leave":
+		h.need(args, 1)
+		h.send(h.seat(args[0]), ws.MsgLeaveRoom, nil)
+	case "drop":
+		h.need(args, 1)
+		h.engine.handleDisconnect(h.seat(args[0]), harnessRoomID)
+		h.sync()
+	case "reconnect":
+		h.need(args, 1)
+		h.engine.handleConnect(h.seat(args[0]))
+		h.sync()
+	case "send":
+		h.need(args, 2)
+		var payload json.RawMessage
+		if len(args) > 2 {
+			payload = json.RawMessage(strings.Join(args[2:], " "))
+		}
+		for _, c := range h.clients(args[0]) {
+			h.send(c, ws.MessageType(args[1]), payload)
+		}
+	case "timeout":
+		h.timeout()
+	case "wait":
+		h.need(args, 1)
+		d, err := time.ParseDuration(args[0])
+		if err != nil {
+			h.fatalf("%v", err)
+		}
+		h.advance(d)
+	case "watch":
+		c := h.newClient(int64(200+len(h.specs)), fmt.Sprintf("s%d", len(h.specs)))
+		h.specs = append(h.specs, c)
+		h.join(c)
+	case "see":
+		h.need(args, 2)
+		h.see(args[0], ws.MessageType(args[1]), strings.Join(args[2:], " "))
+	case "quiet":
+		h.need(args, 1)
+		for _, c := range h.clients(args[0]) {
+			if pending := h.pending(c); len(pending) > 0 {
+				h.fatalf("%s has unread messages: %s", c.Username, describe(pending))
+			}
+		}
+	case "drain":
+		h.need(args, 1)
+		for _, c := range h.clients(args[0]) {
+			h.pending(c)
+			h.inbox[c] = nil
+		}
+	default:
+		h.fatalf("unknown op %q", op)
+	}
+}
+
+func (h *harness) need(args []string, n int) {
+	h.t.Helper()
+	if len(args) < n {
+		h.fatalf("want at least %d arguments", n)
+	}
+}
+
+// table configures the room. It must come before anything touches the
+// room, while the room has no goroutine of its own yet.
+func (h *harness) table(args []string) {
+	h.t.Helper()
+	if h.started {
+		h.fatalf("table must come first")
+	}
+	room := h.hub.GetRoom(harnessRoomID)
+	for _, arg := range args {
+		key, val, _ := strings.Cut(arg, "=")
+		switch key {
+		case "seats":
+			room.Seats = h.atoi(val)
+		case "timer":
+			room.TurnTimer = h.atoi(val)
+		case "bank":
+			room.TimeBank = h.atoi(val)
+		case "variant":
+			room.Rules.Variant = models.Variant(val)
+		case "game":
+			room.Rules.Game = models.GameType(val)
+			room.Rules.InstantWins = models.DefaultInstantWins(room.Rules.Game)
+		case "ranking":
+			room.Rules.FullRanking = true
+		default:
+			h.fatalf("unknown table option %q", key)
+		}
+	}
+}
+
+func (h *harness) atoi(s string) int {
+	h.t.Helper()
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		h.fatalf("%v", err)
+	}
+	return n
+}
+
+// deal seats anyone not yet at the table and readies everybody, stacking
+// the deck so each seat gets the hand given for it. Lobby chatter produced
+// on the way is dropped; each seat's card_dealt, and each spectator's
+// game_state, is left as the next message to read.
+func (h *harness) deal(spec string) {
+	h.t.Helper()
+	var seats int
+	h.hub.DoWait(harnessRoomID, func(room *models.Room) { seats = room.Seats })
+	h.started = true
+
+	marks := make(map[*ws.Client]int)
+	for _, c := range append(h.seats[:seats:seats], h.specs...) {
+		marks[c] = len(h.pending(c))
+	}
+
+	var size int
+	h.hub.DoWait(harnessRoomID, func(room *models.Room) { size = RulesFor(room).HandSize() })
+	if spec != "" {
+		parts := strings.Split(spec, "|")
+		if len(parts) != seats {
+			h.fatalf("%d hands for %d seats", len(parts), seats)
+		}
+		hands := make([][]models.Card, seats)
+		for i, part := range parts {
+			hands[i] = parseCards(h.t, part)
+			if len(hands[i]) != size {
+				h.fatalf("seat %d has %d cards, want %d", i, len(hands[i]), size)
+			}
+		}
+		if err := h.deck.stack(hands); err != nil {
+			h.fatalf("%v", err)
+		}
+	}
+
+	for _, c := range h.seats[:seats] {
+		if c.GetRoom() == 0 {
+			h.join(c)
+		}
+	}
+	for _, c := range h.seats[:seats] {
+		h.send(c, ws.MsgReady, nil)
+	}
+
+	for _, c := range h.clients("*") {
+		want := ws.MsgCardDealt
+		if !h.isSeat(c) {
+			want = ws.MsgGameState
+		}
+		msgs := h.pending(c)
+		mark := marks[c]
+		i := mark
+		for i < len(msgs) && msgs[i].Type != want {
+			i++
+		}
+		if i == len(msgs) {
+			h.fatalf("%s was not dealt in: %s", c.Username, describe(msgs[mark:]))
+		}
+		h.inbox[c] = append(msgs[:mark:mark], msgs[i:]...)
+	}
+}
+
+func (h *harness) join(c *ws.Client) {
+	h.t.Helper()
+	h.send(c, ws.MsgJoinRoom, ws.JoinRoomPayload{RoomID: harnessRoomID})
+	if c.GetRoom() != harnessRoomID {
+		h.fatalf("%s could not join: %s", c.Username, describe(h.pending(c)))
+	}
+}
+
+// send delivers a client message the way the hub routes it and waits for
+// the room to handle it.
+func (h *harness) send(c *ws.Client, typ ws.MessageType, payload interface{}) {
+	h.t.Helper()
+	raw, ok := payload.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(payload); err != nil {
+			h.fatalf("%v", err)
+		}
+	}
+	msg := ws.Message{Type: typ, Payload: raw}
+
+	roomID := c.GetRoom()
+	if typ == ws.MsgJoinRoom {
+		roomID = harnessRoomID
+	}
+	if roomID == 0 {
+		h.engine.HandleMessage(c, nil, msg)
+		return
+	}
+	h.started = true
+	h.hub.DoWait(roomID, func(room *models.Room) {
+		h.engine.HandleMessage(c, room, msg)
+	})
+}
+
+// sync waits until the room has handled every job queued so far.
+func (h *harness) sync() {
+	h.started = true
+	h.hub.DoWait(harnessRoomID, func(*models.Room) {})
+}
+
+// advance runs the clock forward in small steps, letting the room catch up
+// after each, so that timers armed by one deadline can fire within the same
+// call.
+func (h *harness) advance(d time.Duration) {
+	const step = 100 * time.Millisecond
+	h.sync()
+	for d > 0 {
+		s := min(step, d)
+		h.clock.Advance(s)
+		h.sync()
+		d -= s
+	}
+}
+
+func (h *harness) timeout() {
+	h.t.Helper()
+	var deadline time.Time
+	var phase models.GamePhase
+	h.hub.DoWait(harnessRoomID, func(room *models.Room) {
+		deadline, phase = room.TurnDeadline, room.Phase
+	})
+	if phase != models.PhasePlaying {
+		h.fatalf("no turn is running in phase %s", phase)
+	}
+	h.advance(deadline.Sub(h.clock.Now()))
+}
+
+func (h *harness) see(who string, typ ws.MessageType, want string) {
+	h.t.Helper()
+	var wantVal interface{}
+	if want != "" {
+		if err := json.Unmarshal([]byte(want), &wantVal); err != nil {
+			h.fatalf("bad expected payload: %v", err)
+		}
+	}
+	for _, c := range h.clients(who) {
+		msg, ok := h.next(c)
+		if !ok {
+			h.fatalf("%s got nothing, want %s", c.Username, typ)
+		}
+		if msg.Type != typ {
+			h.fatalf("%s got %s %s, want %s", c.Username, msg.Type, msg.Payload, typ)
+		}
+		if wantVal == nil {
+			continue
+		}
+		var got interface{}
+		if err := json.Unmarshal(msg.Payload, &got); err != nil {
+			h.fatalf("%s: bad payload %s: %v", c.Username, msg.Payload, err)
+		}
+		if !matches(wantVal, got) {
+			h.fatalf("%s got %s %s, want %s", c.Username, typ, msg.Payload, want)
+		}
+	}
+}
+
+// pending moves whatever the client has been sent into its inbox and
+// returns the inbox.
+func (h *harness) pending(c *ws.Client) []ws.Message {
+	for {
+		select {
+		case data := <-c.Send:
+			h.inbox[c] = append(h.inbox[c], h.decode(data))
+		default:
+			return h.inbox[c]
+		}
+	}
+}
+
+// next pops the client's next message, waiting briefly if none has arrived.
+func (h *harness) next(c *ws.Client) (ws.Message, bool) {
+	if len(h.pending(c)) == 0 {
+		select {
+		case data := <-c.Send:
+			h.inbox[c] = append(h.inbox[c], h.decode(data))
+		case <-time.After(messageWait):
+			return ws.Message{}, false
+		}
+	}
+	msg := h.inbox[c][0]
+	h.inbox[c] = h.inbox[c][1:]
+	return msg, true
+}
+
+func (h *harness) decode(data []byte) ws.Message {
+	var msg ws.Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		h.fatalf("undecodable message %s: %v", data, err)
+	}
+	return msg
+}
+
+func (h *harness) seat(name string) *ws.Client {
+	h.t.Helper()
+	n, err := strconv.Atoi(name)
+	if err != nil || n < 0 || n >= len(h.seats) {
+		h.fatalf("bad seat %q", name)
+	}
+	return h.seats[n]
+}
+
+func (h *harness) isSeat(c *ws.Client) bool {
+	for _, s := range h.seats {
+		if s == c {
+			return true
+		}
+	}
+	return false
+}
+
+// clients resolves a comma-separated list of names, or "*" for everyone at
+// the table.
+func (h *harness) clients(who string) []*ws.Client {
+	h.t.Helper()
+	if who == "*" {
+		var all []*ws.Client
+		for _, c := range h.seats {
+			if c.GetRoom() == harnessRoomID {
+				all = append(all, c)
+			}
+		}
+		return append(all, h.specs...)
+	}
+	var out []*ws.Client
+	for _, name := range strings.Split(who, ",") {
+		if rest, ok := strings.CutPrefix(name, "s"); ok {
+			n, err := strconv.Atoi(rest)
+			if err != nil || n < 0 || n >= len(h.specs) {
+				h.fatalf("bad spectator %q", name)
+			}
+			out = append(out, h.specs[n])
+			continue
+		}
+		out = append(out, h.seat(name))
+	}
+	return out
+}
+
+func playPayload(cards []models.Card) ws.PlayCardsPayload {
+	p := ws.PlayCardsPayload{Cards: make([]ws.CardPayload, len(cards))}
+	for i, c := range cards {
+		p.Cards[i] = ws.CardPayload{Rank: c.Rank.String(), Suit: c.Suit.String()}
+	}
+	return p
+}
+
+func describe(msgs []ws.Message) string {
+	if len(msgs) == 0 {
+		return "nothing"
+	}
+	parts := make([]string, len(msgs))
+	for i, m := range msgs {
+		parts[i] = fmt.Sprintf("%s %s", m.Type, m.Payload)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// matches reports whether got contains want: every key of a wanted object
+// must match, with null matching a missing key, and arrays must match
+// element by element.
+func matches(want, got interface{}) bool {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, wv := range w {
+			gv, present := g[k]
+			if wv == nil {
+				if present && gv != nil {
+					return false
+				}
+				continue
+			}
+			if !present || !matches(wv, gv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(g) != len(w) {
+			return false
+		}
+		for i := range w {
+			if !matches(w[i], g[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+// stackedRand replays the draws that make models.DealCards lay the deck out
+// in a chosen order, and falls back to the deal's own source once they run
+// out.
+type stackedRand struct {
+	draws    []int
+	fallback models.Rand
+}
+
+func (r *stackedRand) Intn(n int) int {
+	if len(r.draws) == 0 {
+		return r.fallback.Intn(n)
+	}
+	j := r.draws[0]
+	r.draws = r.draws[1:]
+	if j >= n {
+		panic(fmt.Sprintf("stacked draw %d out of range [0, %d)", j, n))
+	}
+	return j
+}
+
+// stack queues the draws for the next deal to give each seat its hand. The
+// undealt cards follow in deck order.
+func (r *stackedRand) stack(hands [][]models.Card) error {
+	var order []models.Card
+	for _, hand := range hands {
+		order = append(order, hand...)
+	}
+	for _, c := range models.NewDeck() {
+		if !models.ContainsCard(order, c) {
+			order = append(order, c)
+		}
+	}
+	if len(order) != 52 {
+		return fmt.Errorf("hands repeat a card")
+	}
+
+	// Run ShuffleDeck's Fisher-Yates backwards: at each step pick the
+	// index that brings the wanted card into place.
+	deck := models.NewDeck()
+	r.draws = r.draws[:0]
+	for i := len(deck) - 1; i > 0; i-- {
+		j := 0
+		for deck[j] != order[i] {
+			j++
+		}
+		r.draws = append(r.draws, j)
+		deck[i], deck[j] = deck[j], deck[i]
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package game
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+const (
+	// historyQueueSize is how many history writes can wait before the
+	// rooms making them block.
+	historyQueueSize = 4096
+	historyTimeout   = 5 * time.Second
+)
+
+// HistoryStore keeps a record of every game: the deal, each move and how
+// it ended, for replays. Writes for a game arrive in the order they
+// happened.
+type HistoryStore interface {
+	CreateGame(ctx context.Context, g *models.GameRecord) error
+	AddMove(ctx context.Context, m *models.GameMove) error
+	FinishGame(ctx context.Context, s *models.Settlement, at time.Time) error
+	AbortGame(ctx context.Context, gameID string, at time.Time) error
+	// GetReplay loads a game with its moves in order, or nil if there is
+	// no such game.
+	GetReplay(ctx context.Context, gameID string) (*models.GameReplay, error)
+}
+
+// historyEvent is one queued history write; exactly one of its pointer
+// fields, or aborted, is set.
+type historyEvent struct {
+	game       *models.GameRecord
+	move       *models.GameMove
+	settlement *models.Settlement
+	aborted    string
+	at         time.Time
+}
+
+// runHistory applies history writes one at a time, numbering each game's
+// moves as they come. A failed write is logged and skipped.
+func (e *Engine) runHistory() {
+	seq := make(map[string]int)
+	for ev := range e.historyQueue {
+		ctx, cancel := context.WithTimeout(context.Background(), historyTimeout)
+		var err error
+		var gameID string
+		switch {
+		case ev.game != nil:
+			gameID = ev.game.GameID
+			err = e.history.CreateGame(ctx, ev.game)
+		case ev.move != nil:
+			gameID = ev.move.GameID
+			seq[gameID]++
+			ev.move.Seq = seq[gameID]
+			err = e.history.AddMove(ctx, ev.move)
+		case ev.settlement != nil:
+			gameID = ev.settlement.GameID
+			delete(seq, gameID)
+			err = e.history.FinishGame(ctx, ev.settlement, ev.at)
+		default:
+			gameID = ev.aborted
+			delete(seq, gameID)
+			err = e.history.AbortGame(ctx, gameID, ev.at)
+		}
+		cancel()
+		if err != nil {
+			log.Printf("game %s: history write failed: %v", gameID, err)
+		}
+	}
+}
+
+func (e *Engine) queueHistory(ev historyEvent) {
+	if e.history != nil {
+		e.historyQueue <- ev
+	}
+}
+
+// recordDeal records a game once it has been dealt and its first seat
+// chosen.
+// Must be called on the room's goroutine.
+func (e *Engine) recordDeal(room *models.Room, firstSeat int) {
+	if e.history == nil {
+		return
+	}
+	g := &models.GameRecord{
+		GameID:      room.GameID,
+		RoomID:      room.ID,
+		Rules:       room.Rules,
+		Seats:       room.Seats,
+		AnteAmount:  room.AnteAmount,
+		FirstSeat:   firstSeat,
+		OpeningCard: room.OpeningCard,
+		StartedAt:   e.clock.Now(),
+		Deck:        room.Deck,
+	}
+	for i := 0; i < room.Seats; i++ {
+		p := room.Players[i]
+		hand := make([]models.Card, len(p.Hand))
+		copy(hand, p.Hand)
+		g.Players = append(g.Players, models.GamePlayerRecord{
+			Seat:     i,
+			UserID:   p.UserID,
+			Username: p.Username,
+			IsBot:    p.IsBot,
+			Hand:     hand,
+		})
+	}
+	e.queueHistory(historyEvent{game: g})
+}
+
+// recordMove records a seat's move in the room's current game.
+// Must be called on the room's goroutine.
+func (e *Engine) recordMove(room *models.Room, seat int, action models.MoveAction, cards []models.Card) {
+	if e.history == nil {
+		return
+	}
+	e.queueHistory(historyEvent{move: &models.GameMove{
+		GameID: room.GameID,
+		Seat:   seat,
+		Action: action,
+		Cards:  cards,
+		At:     e.clock.Now(),
+	}})
+}
+
+func (e *Engine) recordResult(s *models.Settlement) {
+	e.queueHistory(historyEvent{settlement: s, at: e.clock.Now()})
+}
+
+func (e *Engine) recordAbort(gameID string) {
+	e.queueHistory(historyEvent{aborted: gameID, at: e.clock.Now()})
+}
@@ -0,0 +1,152 @@
+package game
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+// memHistory is a HistoryStore that keeps everything in memory and reports
+// each game that ends on ended.
+type memHistory struct {
+	mu      sync.Mutex
+	games   map[string]*models.GameRecord
+	moves   map[string][]models.GameMove
+	results map[string]*models.Settlement
+	aborted map[string]bool
+	ended   chan string
+}
+
+func newMemHistory() *memHistory {
+	return &memHistory{
+		games:   make(map[string]*models.GameRecord),
+		moves:   make(map[string][]models.GameMove),
+		results: make(map[string]*models.Settlement),
+		aborted: make(map[string]bool),
+		ended:   make(chan string, 16),
+	}
+}
+
+func (m *memHistory) CreateGame(_ context.Context, g *models.GameRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.games[g.GameID] = g
+	return nil
+}
+
+func (m *memHistory) AddMove(_ context.Context, mv *models.GameMove) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.moves[mv.GameID] = append(m.moves[mv.GameID], *mv)
+	return nil
+}
+
+func (m *memHistory) FinishGame(_ context.Context, s *models.Settlement, _ time.Time) error {
+	m.mu.Lock()
+	m.results[s.GameID] = s
+	m.mu.Unlock()
+	m.ended <- s.GameID
+	return nil
+}
+
+func (m *memHistory) AbortGame(_ context.Context, gameID string, _ time.Time) error {
+	m.mu.Lock()
+	m.aborted[gameID] = true
+	m.mu.Unlock()
+	m.ended <- gameID
+	return nil
+}
+
+func (m *memHistory) GetReplay(_ context.Context, gameID string) (*models.GameReplay, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g := m.games[gameID]
+	if g == nil {
+		return nil, nil
+	}
+	rep := &models.GameReplay{
+		Game:       *g,
+		Status:     models.GameInProgress,
+		Moves:      append([]models.GameMove{}, m.moves[gameID]...),
+		Settlement: m.results[gameID],
+	}
+	if rep.Settlement != nil {
+		rep.Status = models.GameFinished
+	} else if m.aborted[gameID] {
+		rep.Status = models.GameAborted
+	}
+	return rep, nil
+}
+
+func (m *memHistory) waitEnded(t *testing.T) string {
+	t.Helper()
+	select {
+	case id := <-m.ended:
+		return id
+	case <-time.After(messageWait):
+		t.Fatal("no game ended")
+		return ""
+	}
+}
+
+func TestHistoryRecordsDealMovesAndAbort(t *testing.T) {
+	store := newMemHistory()
+	newHarness(t, store).run(`
+table seats=2
+` + twoSeatDeal + `
+play 0 3S
+timeout
+timeout
+leave 1
+`)
+	id := store.waitEnded(t)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	g := store.games[id]
+	if g == nil {
+		t.Fatal("game was not recorded")
+	}
+	if g.FirstSeat != 0 || g.OpeningCard == nil || *g.OpeningCard != models.ThreeOfSpades() || len(g.Players) != 2 {
+		t.Errorf("game record = %+v", g)
+	}
+	if want := parseCards(t, "4C 4D 5C 5D 6C 6D 8H 9H 10H JH QH KH AH"); !reflect.DeepEqual(g.Players[1].Hand, want) {
+		t.Errorf("seat 1 dealt %v, want %v", g.Players[1].Hand, want)
+	}
+
+	want := []models.GameMove{
+		{GameID: id, Seq: 1, Seat: 0, Action: models.MovePlay, Cards: parseCards(t, "3S"), At: time.Unix(0, 0)},
+		{GameID: id, Seq: 2, Seat: 1, Action: models.MoveTimeout, At: time.Unix(60, 0)},
+	}
+	if got := store.moves[id]; !reflect.DeepEqual(got, want) {
+		t.Errorf("moves = %+v, want %+v", got, want)
+	}
+	if !store.aborted[id] {
+		t.Error("game was not marked aborted")
+	}
+}
+
+func TestHistoryRecordsSettlement(t *testing.T) {
+	store := newMemHistory()
+	newHarness(t, store).run(`
+table seats=2
+deal 3S 3C 4S 5S 6S 7S 8S 9S 10S JS QS KS 4C | 2S 2C 2D 2H AS AC AD AH 5C 6C 7C 8C 9C
+see * card_dealt
+see * settlement
+`)
+	id := store.waitEnded(t)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	s := store.results[id]
+	if s == nil || s.Winner != 1 || s.Reason != models.SettleInstantWin {
+		t.Fatalf("settlement = %+v", s)
+	}
+	if store.games[id] == nil || len(store.moves[id]) != 0 {
+		t.Errorf("game = %+v, moves = %+v", store.games[id], store.moves[id])
+	}
+}
@@ -0,0 +1,76 @@
+package game
+
+import (
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+// FindInstantWin checks the freshly dealt hands for an instant win enabled by
+// the room's rules, as recognised by its rule set. The strongest kind wins;
+// ties go to the first seat in turn order starting from firstSeat.
+func FindInstantWin(room *models.Room, firstSeat int) (int, models.InstantWin, bool) {
+	if firstSeat < 0 {
+		firstSeat = 0
+	}
+	rules := RulesFor(room)
+	for _, kind := range models.AllInstantWins {
+		if !room.Rules.InstantWinEnabled(kind) {
+			continue
+		}
+		for i := 0; i < room.Seats; i++ {
+			seat := (firstSeat + i) % room.Seats
+			p := room.Players[seat]
+			if p != nil && rules.IsInstantWin(p.Hand, kind) {
+				return seat, kind, true
+			}
+		}
+	}
+	return -1, "", false
+}
+
+// IsInstantWin reports whether a full 13-card hand qualifies as kind.
+func IsInstantWin(hand []models.Card, kind models.InstantWin) bool {
+	var counts [13]int
+	for _, c := range hand {
+		counts[c.Rank]++
+	}
+
+	switch kind {
+	case models.InstantWinDragon:
+		for r := models.Three; r <= models.Ace; r++ {
+			if counts[r] == 0 {
+				return false
+			}
+		}
+		return true
+	case models.InstantWinFourTwos:
+		return counts[models.Two] == 4
+	case models.InstantWinFiveConsecPairs:
+		run := 0
+		for r := models.Three; r <= models.Ace; r++ {
+			if counts[r] >= 2 {
+				run++
+				if run == 5 {
+					return true
+				}
+			} else {
+				run = 0
+			}
+		}
+		return false
+	case models.InstantWinSixPairs:
+		pairs := 0
+		for _, n := range counts {
+			pairs += n / 2
+		}
+		return pairs >= 6
+	case models.InstantWinFourTriples:
+		triples := 0
+		for _, n := range counts {
+			if n >= 3 {
+				triples++
+			}
+		}
+		return triples >= 4
+	}
+	return false
+}
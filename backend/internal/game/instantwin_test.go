@@ -0,0 +1,84 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+func TestMienNamIsInstantWin(t *testing.T) {
+	tests := []struct {
+		name string
+		hand string
+		kind models.InstantWin
+		want bool
+	}{
+		{"dragon", "3S 4S 5C 6D 7H 8S 9S 10S JS QS KS AS 2H", models.InstantWinDragon, true},
+		{"dragon missing a rank", "3S 3C 5C 6D 7H 8S 9S 10S JS QS KS AS 2H", models.InstantWinDragon, false},
+		{"four twos", "2S 2C 2D 2H 3S 4C 5D 7H 8S 9C JD KH AS", models.InstantWinFourTwos, true},
+		{"three twos", "2S 2C 2D 3H 3S 4C 5D 7H 8S 9C JD KH AS", models.InstantWinFourTwos, false},
+		{"five consecutive pairs", "3S 3C 4S 4C 5S 5C 6S 6C 7S 7C 9H JD KH", models.InstantWinFiveConsecPairs, true},
+		{"five pairs with a gap", "3S 3C 4S 4C 5S 5C 6S 6C 8S 8C 9H JD KH", models.InstantWinFiveConsecPairs, false},
+		{"pairs running into 2s", "JS JC QS QC KS KC AS AC 2S 2C 3H 5D 7H", models.InstantWinFiveConsecPairs, false},
+		{"six pairs", "3S 3C 5S 5C 7S 7C 9S 9C JS JC KS KC AH", models.InstantWinSixPairs, true},
+		{"a triple is one pair", "3S 3C 3D 5S 5C 7S 7C 9S 9C JS JC KH AH", models.InstantWinSixPairs, false},
+		{"four of a kind is two pairs", "3S 3C 3D 3H 5S 5C 7S 7C 9S 9C JS JH AH", models.InstantWinSixPairs, true},
+		{"four triples", "3S 3C 3D 5S 5C 5D 7S 7C 7D 9S 9C 9D KH", models.InstantWinFourTriples, true},
+		{"three triples", "3S 3C 3D 5S 5C 5D 7S 7C 7D 9S 9C 10D KH", models.InstantWinFourTriples, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (MienNam{}).IsInstantWin(parseCards(t, tt.hand), tt.kind); got != tt.want {
+				t.Errorf("IsInstantWin(%s, %s) = %v, want %v", tt.hand, tt.kind, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindInstantWin(t *testing.T) {
+	const (
+		dragon   = "3S 4S 5C 6D 7H 8S 9S 10S JS QS KS AS 2H"
+		sixPairs = "3S 3C 5S 5C 7S 7C 9S 9C JS JC KS KC AH"
+		plain    = "3D 4D 5D 6S 8D 8H 10C JD QH 2S 4H 6H 9D"
+	)
+	tests := []struct {
+		name      string
+		hands     []string
+		disabled  models.InstantWin
+		firstSeat int
+		seat      int
+		kind      models.InstantWin
+	}{
+		{"none", []string{plain, plain}, "", 0, -1, ""},
+		{"only one", []string{plain, sixPairs}, "", 0, 1, models.InstantWinSixPairs},
+		{"strongest wins", []string{sixPairs, dragon}, "", 0, 1, models.InstantWinDragon},
+		{"tie goes to first seat", []string{sixPairs, sixPairs}, "", 0, 0, models.InstantWinSixPairs},
+		{"tie counts from firstSeat", []string{sixPairs, sixPairs}, "", 1, 1, models.InstantWinSixPairs},
+		{"tie wraps past the last seat", []string{sixPairs, plain, sixPairs}, "", 1, 2, models.InstantWinSixPairs},
+		{"no first seat", []string{sixPairs, sixPairs}, "", -1, 0, models.InstantWinSixPairs},
+		{"disabled kind", []string{dragon, sixPairs}, models.InstantWinDragon, 0, 1, models.InstantWinSixPairs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := models.NewRoom(1, "test", 100)
+			room.Seats = len(tt.hands)
+			for i, hand := range tt.hands {
+				room.Players[i] = &models.Player{SeatIndex: i, Hand: parseCards(t, hand)}
+			}
+			if tt.disabled != "" {
+				var wins []models.InstantWin
+				for _, k := range room.Rules.InstantWins {
+					if k != tt.disabled {
+						wins = append(wins, k)
+					}
+				}
+				room.Rules.InstantWins = wins
+			}
+
+			seat, kind, ok := FindInstantWin(room, tt.firstSeat)
+			if seat != tt.seat || kind != tt.kind || ok != (tt.seat >= 0) {
+				t.Errorf("FindInstantWin = %d, %q, %v; want %d, %q", seat, kind, ok, tt.seat, tt.kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package game
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/ws"
+)
+
+// Joins sent to two rooms at once run on different goroutines; the client
+// must end up seated in exactly one of them.
+func TestJoinClaimsOneRoom(t *testing.T) {
+	h := newHarness(t, nil)
+	c := h.seats[0]
+	for first := 1; first < 600; first += 2 {
+		rooms := []int{first, first + 1}
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for _, id := range rooms {
+			payload, _ := json.Marshal(ws.JoinRoomPayload{RoomID: id})
+			msg := ws.Message{Type: ws.MsgJoinRoom, Payload: payload}
+			wg.Add(1)
+			go func(id int) {
+				defer wg.Done()
+				h.hub.DoWait(id, func(room *models.Room) {
+					<-start
+					h.engine.HandleMessage(c, room, msg)
+				})
+			}(id)
+		}
+		close(start)
+		wg.Wait()
+
+		var seated []int
+		for _, id := range rooms {
+			h.hub.DoWait(id, func(room *models.Room) {
+				if _, p := room.FindPlayerByUserID(c.UserID); p != nil {
+					seated = append(seated, id)
+				}
+			})
+		}
+		if len(seated) != 1 || c.GetRoom() != seated[0] {
+			t.Fatalf("joining rooms %v at once: seated in %v, client in room %d", rooms, seated, c.GetRoom())
+		}
+		h.hub.DoWait(seated[0], func(room *models.Room) {
+			h.engine.HandleMessage(c, room, ws.Message{Type: ws.MsgLeaveRoom})
+		})
+	}
+}
+
+// A join that finds no seat and no room to watch leaves the client free to
+// join elsewhere.
+func TestJoinFullRoomReleasesClaim(t *testing.T) {
+	h := newHarness(t, nil)
+	h.hub.DoWait(harnessRoomID, func(room *models.Room) {
+		for i := 0; i < room.Seats; i++ {
+			room.Players[i] = &models.Player{UserID: int64(200 + i), SeatIndex: i}
+		}
+		for i := 0; i < models.MaxSpectators; i++ {
+			room.AddSpectator(&models.Spectator{UserID: int64(300 + i)})
+		}
+	})
+	c := h.seats[0]
+	h.send(c, ws.MsgJoinRoom, ws.JoinRoomPayload{RoomID: harnessRoomID})
+	h.run(`see 0 error {"error":"room is full"}`)
+	if c.GetRoom() != 0 {
+		t.Fatalf("client left in room %d after a failed join", c.GetRoom())
+	}
+
+	payload, _ := json.Marshal(ws.JoinRoomPayload{RoomID: harnessRoomID + 1})
+	h.hub.DoWait(harnessRoomID+1, func(room *models.Room) {
+		h.engine.HandleMessage(c, room, ws.Message{Type: ws.MsgJoinRoom, Payload: payload})
+	})
+	if c.GetRoom() != harnessRoomID+1 {
+		t.Fatalf("could not join another room after a failed join: %s", describe(h.pending(c)))
+	}
+}
@@ -0,0 +1,128 @@
+package game
+
+import (
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+// MienBac is Northern Tien Len:
+//   - sequences must be a single suit, and double sequences are not played;
+//   - pairs must be one colour (both red or both black);
+//   - 2s may only be played as singles;
+//   - a play must follow the table: singles and pairs by colour, sequences
+//     by suit. A 2 may be played on any lower single, but a 2 on a 2 must
+//     follow colour;
+//   - only four-of-a-kind chops, and only a single 2 or a lower
+//     four-of-a-kind.
+//
+// Instant wins and settlement follow Mien Nam.
+type MienBac struct {
+	MienNam
+}
+
+func (MienBac) Variant() models.Variant { return models.VariantMienBac }
+
+func (MienBac) Classify(cards []models.Card) (models.CombinationType, bool) {
+	combo, ok := ClassifyCombination(cards)
+	if !ok {
+		return 0, false
+	}
+	if combo != models.ComboSingle && containsTwo(cards) {
+		return 0, false
+	}
+	switch combo {
+	case models.ComboPair:
+		if redCount(cards) == 1 {
+			return 0, false
+		}
+	case models.ComboSequence:
+		if !sameSuit(cards) {
+			return 0, false
+		}
+	case models.ComboDoubleSequence:
+		return 0, false
+	}
+	return combo, true
+}
+
+func (MienBac) CanBeat(table *models.TablePlay, play []models.Card, playCombo models.CombinationType) bool {
+	if table == nil {
+		return true
+	}
+	tableCards := table.Cards
+	tableCombo := table.ComboType
+
+	// Four-of-a-kind chops a single 2
+	if tableCombo == models.ComboSingle && tableCards[0].Rank == models.Two {
+		if playCombo == models.ComboFourOfAKind {
+			return true
+		}
+	}
+
+	if playCombo != tableCombo || len(play) != len(tableCards) {
+		return false
+	}
+
+	models.SortCards(play)
+	tableSorted := make([]models.Card, len(tableCards))
+	copy(tableSorted, tableCards)
+	models.SortCards(tableSorted)
+
+	playHigh := play[len(play)-1]
+	tableHigh := tableSorted[len(tableSorted)-1]
+	if playHigh.Value() <= tableHigh.Value() {
+		return false
+	}
+
+	switch playCombo {
+	case models.ComboSingle:
+		if playHigh.Rank == models.Two && tableHigh.Rank != models.Two {
+			return true
+		}
+		return isRed(playHigh) == isRed(tableHigh)
+	case models.ComboPair:
+		return redCount(play) == redCount(tableSorted)
+	case models.ComboSequence:
+		return playHigh.Suit == tableHigh.Suit
+	}
+	return true
+}
+
+// BombRank: four-of-a-kind is the only bomb.
+func (MienBac) BombRank(combo models.CombinationType, n int) int {
+	if combo == models.ComboFourOfAKind {
+		return 1
+	}
+	return 0
+}
+
+func isRed(c models.Card) bool {
+	return c.Suit == models.Diamonds || c.Suit == models.Hearts
+}
+
+func redCount(cards []models.Card) int {
+	n := 0
+	for _, c := range cards {
+		if isRed(c) {
+			n++
+		}
+	}
+	return n
+}
+
+func sameSuit(cards []models.Card) bool {
+	for _, c := range cards[1:] {
+		if c.Suit != cards[0].Suit {
+			return false
+		}
+	}
+	return true
+}
+
+func containsTwo(cards []models.Card) bool {
+	for _, c := range cards {
+		if c.Rank == models.Two {
+			return true
+		}
+	}
+	return false
+}
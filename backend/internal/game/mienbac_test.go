@@ -0,0 +1,125 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+// parseCards turns "3S 4S 10H" into cards.
+func parseCards(t *testing.T, s string) []models.Card {
+	t.Helper()
+	cards, err := models.ParseCards(s)
+	if err != nil {
+		t.Fatalf("bad cards %q: %v", s, err)
+	}
+	return cards
+}
+
+func TestMienBacClassify(t *testing.T) {
+	tests := []struct {
+		cards string
+		combo models.CombinationType
+		ok    bool
+	}{
+		{"7H", models.ComboSingle, true},
+		{"2D", models.ComboSingle, true},
+		{"7S 7C", models.ComboPair, true},
+		{"7D 7H", models.ComboPair, true},
+		{"7S 7H", 0, false},
+		{"2S 2C", 0, false},
+		{"9S 9C 9H", models.ComboTriple, true},
+		{"2S 2C 2H", 0, false},
+		{"JS JC JD JH", models.ComboFourOfAKind, true},
+		{"3S 4S 5S", models.ComboSequence, true},
+		{"9H 10H JH QH KH AH", models.ComboSequence, true},
+		{"3S 4S 5C", 0, false},
+		{"QD KD AD 2D", 0, false},
+		{"3S 3C 4S 4C 5S 5C", 0, false},
+		{"3S 5S", 0, false},
+	}
+	for _, tt := range tests {
+		combo, ok := MienBac{}.Classify(parseCards(t, tt.cards))
+		if ok != tt.ok || (ok && combo != tt.combo) {
+			t.Errorf("Classify(%s) = %v, %v; want %v, %v", tt.cards, combo, ok, tt.combo, tt.ok)
+		}
+	}
+}
+
+func TestMienBacCanBeat(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		play  string
+		want  bool
+	}{
+		{"lead", "", "3S", true},
+		{"single same colour", "5S", "9C", true},
+		{"single other colour", "5S", "9H", false},
+		{"single lower", "9C", "5S", false},
+		{"single same rank higher suit", "9S", "9C", true},
+		{"two on any single", "AS", "2H", true},
+		{"two on two same colour", "2D", "2H", true},
+		{"two on two other colour", "2C", "2H", false},
+		{"pair same colour", "5S 5C", "8S 8C", true},
+		{"red pair on black pair", "5S 5C", "8D 8H", false},
+		{"triple any colour", "5S 5C 5H", "8S 8D 8H", true},
+		{"sequence same suit", "3S 4S 5S", "4S 5S 6S", true},
+		{"sequence other suit", "3S 4S 5S", "4H 5H 6H", false},
+		{"sequence length differs", "3S 4S 5S", "4S 5S 6S 7S", false},
+		{"four chops single two", "2H", "6S 6C 6D 6H", true},
+		{"four over four", "6S 6C 6D 6H", "9S 9C 9D 9H", true},
+		{"four does not chop pair", "KS KC", "6S 6C 6D 6H", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := MienBac{}
+			var table *models.TablePlay
+			if tt.table != "" {
+				cards := parseCards(t, tt.table)
+				combo, ok := rules.Classify(cards)
+				if !ok {
+					t.Fatalf("table %s is not a valid play", tt.table)
+				}
+				table = &models.TablePlay{Cards: cards, ComboType: combo}
+			}
+			play := parseCards(t, tt.play)
+			combo, ok := rules.Classify(play)
+			if !ok {
+				t.Fatalf("play %s is not a valid play", tt.play)
+			}
+			if got := rules.CanBeat(table, play, combo); got != tt.want {
+				t.Errorf("CanBeat(%s, %s) = %v, want %v", tt.table, tt.play, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMienBacChop(t *testing.T) {
+	room := models.NewRoom(1, "test", 100)
+	room.Rules.Variant = models.VariantMienBac
+	room.TablePlay = &models.TablePlay{PlayerIndex: 2, Cards: parseCards(t, "2H"), ComboType: models.ComboSingle}
+
+	chop := findChop(room, 3, parseCards(t, "6S 6C 6D 6H"), models.ComboFourOfAKind)
+	if chop == nil {
+		t.Fatal("four-of-a-kind on a 2 should be a chop")
+	}
+	if chop.Chopped != 2 || chop.Chopper != 3 || chop.Value != 200 {
+		t.Errorf("chop = %+v, want seat 3 chopping seat 2 for 200", chop)
+	}
+}
+
+func TestRulesFor(t *testing.T) {
+	room := models.NewRoom(1, "test", 100)
+	if v := RulesFor(room).Variant(); v != models.VariantMienNam {
+		t.Errorf("default variant = %s, want %s", v, models.VariantMienNam)
+	}
+	room.Rules.Variant = models.VariantMienBac
+	if v := RulesFor(room).Variant(); v != models.VariantMienBac {
+		t.Errorf("variant = %s, want %s", v, models.VariantMienBac)
+	}
+	room.Rules.Variant = "unknown"
+	if v := RulesFor(room).Variant(); v != models.VariantMienNam {
+		t.Errorf("unknown variant fell back to %s, want %s", v, models.VariantMienNam)
+	}
+}
@@ -0,0 +1,47 @@
+package game
+
+import (
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+// MienNam is Southern Tien Len, the default rule set. Suits only break ties
+// between cards of the same rank.
+type MienNam struct{}
+
+func (MienNam) Variant() models.Variant { return models.VariantMienNam }
+
+func (MienNam) Classify(cards []models.Card) (models.CombinationType, bool) {
+	return ClassifyCombination(cards)
+}
+
+func (MienNam) CanBeat(table *models.TablePlay, play []models.Card, playCombo models.CombinationType) bool {
+	return CanBeat(table, play, playCombo)
+}
+
+func (MienNam) BombRank(combo models.CombinationType, n int) int {
+	return BombRank(combo, n)
+}
+
+func (MienNam) PigValue(twos []models.Card) int {
+	return pigValue(twos)
+}
+
+func (MienNam) OpeningCard() models.Card {
+	return models.ThreeOfSpades()
+}
+
+func (MienNam) IsInstantWin(hand []models.Card, kind models.InstantWin) bool {
+	return IsInstantWin(hand, kind)
+}
+
+func (MienNam) LossMultiplier(hand []models.Card, cardCount int) int {
+	return deadPigMultiplier(hand, cardCount)
+}
+
+func (MienNam) MaxLossMultiplier(seats int) int {
+	return maxLossMultiplier
+}
+
+func (MienNam) HandSize() int {
+	return 13
+}
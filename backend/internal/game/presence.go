@@ -0,0 +1,195 @@
+package game
+
+import (
+	"log"
+	"time"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/ws"
+)
+
+const (
+	// autoPlayDelay is how long a bot waits before moving for an absent
+	// player, so the table can follow what happened.
+	autoPlayDelay = 1500 * time.Millisecond
+
+	// afkTurnsBeforeTakeover is how many turns in a row a connected player
+	// can time out before a bot starts playing for them.
+	afkTurnsBeforeTakeover = 2
+)
+
+// AutoPlayer chooses moves for a seat whose owner is away, under the room's
+// rule set. An empty result means pass.
+type AutoPlayer interface {
+	ChooseMove(rules RuleSet, hand []models.Card, table *models.TablePlay) []models.Card
+}
+
+// handleDisconnect keeps a dropped player's seat until the game ends so they
+// can reconnect; a bot plays for them in the meantime, and the game settles
+// as usual. Outside a game the player simply leaves. It runs on the hub
+// goroutine, so the player is marked away before any reconnect can be
+// handled.
+func (e *Engine) handleDisconnect(client *ws.Client, roomID int) {
+	e.awayMu.Lock()
+	e.away[client.UserID] = roomID
+	e.awayMu.Unlock()
+
+	e.hub.Do(roomID, func(room *models.Room) {
+		_, player := room.FindPlayerByUserID(client.UserID)
+		if player == nil || room.Phase == models.PhaseLobby {
+			e.clearAway(client.UserID, roomID)
+			e.leaveRoom(room, client.UserID)
+			return
+		}
+		player.Disconnected = true
+		e.broadcastPlayerStatus(room, player)
+		if (room.Phase == models.PhasePlaying || room.Phase == models.PhaseDeclaring) && !player.AutoPlay {
+			e.setAutoPlay(room, player, true, "disconnected")
+			switch {
+			case room.Phase == models.PhaseDeclaring:
+				e.withdrawDeclaration(room, player.SeatIndex)
+			case room.CurrentTurn == player.SeatIndex:
+				e.startTurnTimer(room)
+			}
+		}
+
+		log.Printf("room %d: user %d disconnected, holding seat until the game ends", roomID, client.UserID)
+	})
+}
+
+// handleConnect puts a reconnecting player back into their seat and sends
+// them the full game state.
+func (e *Engine) handleConnect(client *ws.Client) {
+	roomID := client.GetRoom()
+	if roomID == 0 {
+		e.awayMu.Lock()
+		roomID = e.away[client.UserID]
+		e.awayMu.Unlock()
+	}
+	if roomID == 0 {
+		return
+	}
+
+	e.hub.Do(roomID, func(room *models.Room) {
+		idx, player := room.FindPlayerByUserID(client.UserID)
+		if player == nil {
+			e.clearAway(client.UserID, roomID)
+			return
+		}
+		client.SetRoom(roomID)
+		if player.Disconnected {
+			player.Disconnected = false
+			e.broadcastPlayerStatus(room, player)
+		}
+		if player.AutoPlay {
+			player.ResumePending = true
+		}
+		data, _ := ws.NewMessage(ws.MsgGameState, buildGameStateForPlayer(room, idx))
+
+		e.clearAway(client.UserID, roomID)
+		client.Deliver(data)
+		log.Printf("room %d: user %d reconnected to seat %d", roomID, client.UserID, idx)
+	})
+}
+
+func (e *Engine) clearAway(userID int64, roomID int) {
+	e.awayMu.Lock()
+	if e.away[userID] == roomID {
+		delete(e.away, userID)
+	}
+	e.awayMu.Unlock()
+}
+
+// broadcastPlayerStatus must be called on the room's goroutine
+func (e *Engine) broadcastPlayerStatus(room *models.Room, p *models.Player) {
+	data, _ := ws.NewMessage(ws.MsgPlayerStatus, map[string]interface{}{
+		"seat_index": p.SeatIndex,
+		"user_id":    p.UserID,
+		"connected":  !p.Disconnected,
+	})
+	e.hub.Broadcast(room, data)
+}
+
+func (e *Engine) handleResumeControl(client *ws.Client, room *models.Room) {
+	if _, player := room.FindPlayerByUserID(client.UserID); player != nil {
+		e.playerActed(player)
+	}
+}
+
+// playerActed records that the human in this seat is present. A seat being
+// auto-played is handed back at the next turn boundary.
+// Must be called on the room's goroutine.
+func (e *Engine) playerActed(p *models.Player) {
+	p.MissedTurns = 0
+	if p.AutoPlay && !p.Disconnected {
+		p.ResumePending = true
+	}
+}
+
+// handBackControl ends auto-play for every seat whose owner has returned.
+// It runs at each turn boundary.
+// Must be called on the room's goroutine.
+func (e *Engine) handBackControl(room *models.Room) {
+	for _, p := range room.Players {
+		if p != nil && p.ResumePending {
+			p.ResumePending = false
+			p.MissedTurns = 0
+			e.setAutoPlay(room, p, false, "returned")
+		}
+	}
+}
+
+// setAutoPlay must be called on the room's goroutine
+func (e *Engine) setAutoPlay(room *models.Room, p *models.Player, on bool, reason string) {
+	p.AutoPlay = on
+	data, _ := ws.NewMessage(ws.MsgAutoPlay, map[string]interface{}{
+		"seat_index":  p.SeatIndex,
+		"user_id":     p.UserID,
+		"auto_played": on,
+		"reason":      reason,
+	})
+	e.hub.Broadcast(room, data)
+	log.Printf("room %d: seat %d auto-play=%v (%s)", room.ID, p.SeatIndex, on, reason)
+}
+
+// autoMove plays the current turn on behalf of an absent player. If the
+// chosen move is rejected the seat passes, or leads its lowest card when it
+// has to start the round.
+// Must be called on the room's goroutine.
+func (e *Engine) autoMove(room *models.Room, idx int) {
+	p := room.Players[idx]
+
+	if e.auto != nil {
+		hand := make([]models.Card, len(p.Hand))
+		copy(hand, p.Hand)
+		if cards := e.auto.ChooseMove(RulesFor(room), hand, room.TablePlay); len(cards) > 0 {
+			err := e.playCards(room, idx, cards)
+			if err == nil {
+				return
+			}
+			log.Printf("room %d: auto-play move for seat %d rejected: %v", room.ID, idx, err)
+		}
+	}
+
+	if room.TablePlay == nil && e.leadLowest(room, idx) {
+		return
+	}
+	e.passTurn(room, idx, models.MovePass)
+}
+
+// leadLowest starts a round with the seat's lowest card. Used when a seat
+// that must lead cannot or does not choose a play itself.
+// Must be called on the room's goroutine.
+func (e *Engine) leadLowest(room *models.Room, idx int) bool {
+	hand := room.Players[idx].Hand
+	if len(hand) == 0 {
+		return false
+	}
+	lowest := hand[0]
+	for _, c := range hand[1:] {
+		if c.Value() < lowest.Value() {
+			lowest = c
+		}
+	}
+	return e.playCards(room, idx, []models.Card{lowest}) == nil
+}
@@ -0,0 +1,110 @@
+package game
+
+import "testing"
+
+// A dropped player's seat is played by the bot for as long as the game
+// runs, and the game settles as usual.
+func TestScenarioDisconnectedSeatPlaysOn(t *testing.T) {
+	runScenario(t, `
+table seats=2 timer=120 bank=0
+deal 3S 4S 5S 6S 7S 8S 9S 10S JS QS KS 3C 2S | 4C 4D 5C 5D 6C 6D 8H 9H 10H JH QH KH AH
+drain *
+play 0 3S 4S 5S 6S 7S 8S 9S 10S JS QS KS
+drain *
+drop 1
+see * player_status {"seat_index":1,"connected":false}
+see * auto_play {"seat_index":1,"auto_played":true,"reason":"disconnected"}
+quiet *
+
+wait 2s
+see * turn_change {"action":"pass","player_index":1,"current_turn":0,"table_clear":true}
+
+# Long after any grace period the game is still on.
+wait 90s
+quiet *
+
+play 0 3C
+see * move_played
+see * turn_change {"current_turn":1}
+wait 2s
+see * turn_change {"action":"pass","player_index":1,"current_turn":0}
+play 0 2S
+see * move_played
+see * settlement {"winner":0,"reason":"cards_out","results":[{"seat":0},{"seat":1,"cards_left":13}]}
+`)
+}
+
+// A player who drops while Sâm Lốc declarations are open gives up their
+// chance to declare, and the bot plays their seat once play starts.
+func TestScenarioDisconnectWhileDeclaring(t *testing.T) {
+	runScenario(t, `
+table seats=2 game=sam_loc
+deal 3C 4D 5H 6C 8D 8H 10C JD QH 5C | 3S 4S 5S 6S 7S 9C 9D KH AH 2D
+see * card_dealt {"phase":"DECLARING"}
+drop 1
+see * player_status {"seat_index":1,"connected":false}
+see * auto_play {"seat_index":1,"auto_played":true,"reason":"disconnected"}
+send 1 declare_sam {"declare":true}
+see 1 error {"error":"you cannot declare now"}
+
+# Seat 0 was the last who could declare, so play starts at once, and
+# seat 1 leads from the 3 of spades on the bot's short clock.
+send 0 declare_sam {"declare":false}
+see * turn_change {"current_turn":1,"deadline":1500}
+wait 2s
+see * move_played {"player_index":1,"cards":[{"rank":"3","suit":"S"}]}
+`)
+}
+
+// A player who comes back mid-game is sent everything they need to pick up
+// where they left off, and the table hears they are back.
+func TestScenarioReconnect(t *testing.T) {
+	runScenario(t, `
+table seats=2
+`+twoSeatDeal+`
+drain *
+play 0 3S
+drain *
+drop 1
+drain *
+reconnect 1
+see * player_status {"seat_index":1,"connected":true}
+see 1 game_state {"current_turn":1,"table_play":{"player_index":0,"cards":[{"rank":"3","suit":"S"}]},"players":[{"card_count":12},{"card_count":13,"connected":true}]}
+quiet *
+`)
+}
+
+// A player who times out twice in a row is taken over by the bot, and gets
+// their seat back at the next turn boundary once they ask for it.
+func TestScenarioAFKTakeoverAndHandBack(t *testing.T) {
+	runScenario(t, `
+table seats=2 timer=10 bank=0
+`+twoSeatDeal+`
+drain *
+play 0 3S
+timeout
+drain *
+play 0 3C
+see * move_played
+see * turn_change {"current_turn":1,"deadline":20000}
+timeout
+see * auto_play {"seat_index":1,"auto_played":true,"reason":"afk"}
+see * turn_change {"action":"timeout","player_index":1,"current_turn":0}
+quiet *
+
+# The bot moves for seat 1 on its short clock.
+play 0 4S
+see * move_played
+see * turn_change {"current_turn":1,"deadline":21500}
+send 1 resume_control
+quiet *
+wait 2s
+see * auto_play {"seat_index":1,"auto_played":false,"reason":"returned"}
+see * turn_change {"action":"pass","player_index":1,"current_turn":0}
+
+# Seat 1 plays its own turns again.
+play 0 5S
+see * move_played
+see * turn_change {"current_turn":1,"deadline":32000}
+`)
+}
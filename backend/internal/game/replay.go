@@ -0,0 +1,363 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/game-playzui/tienlen-server/internal/clock"
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/ws"
+)
+
+const (
+	minReplaySpeed = 0.25
+	maxReplaySpeed = 8
+
+	// maxReplayGap caps the pause between two replayed events, before
+	// speed is applied, so a long think does not stall the replay.
+	maxReplayGap = 5 * time.Second
+)
+
+// ReplayEvent is one message a seat received while a game was played, and
+// when it was sent.
+type ReplayEvent struct {
+	Type    ws.MessageType
+	Payload interface{}
+	At      time.Time
+}
+
+// BuildReplay plays a recorded game again under its rules and returns the
+// messages seat would have received live: the deal, every move and turn
+// change, and the recorded settlement. A seat of -1 watches as a spectator
+// and is shown no hand, nor the seeds the hands were dealt from. It fails
+// if the recorded moves do not follow the rules.
+func BuildReplay(rep *models.GameReplay, seat int) ([]ReplayEvent, error) {
+	var events []ReplayEvent
+	emit := func(typ ws.MessageType, payload interface{}, at time.Time) {
+		events = append(events, ReplayEvent{Type: typ, Payload: payload, At: at})
+	}
+	if _, err := simulate(rep, seat, emit); err != nil {
+		return nil, err
+	}
+
+	if rep.Settlement != nil {
+		at := rep.Game.StartedAt
+		if n := len(rep.Moves); n > 0 {
+			at = rep.Moves[n-1].At
+		}
+		if rep.EndedAt != nil {
+			at = *rep.EndedAt
+		}
+		settlement := rep.Settlement
+		if seat < 0 {
+			settlement = rep.Public().Settlement
+		}
+		emit(ws.MsgSettlement, settlement, at)
+	}
+	return events, nil
+}
+
+// SimulateGame plays a recorded game again under its rules and returns the
+// settlement it should have ended with, or nil if the moves stop before
+// the game is over. It fails if the recorded moves do not follow the rules.
+func SimulateGame(rep *models.GameReplay) (*models.Settlement, error) {
+	return simulate(rep, -1, func(ws.MessageType, interface{}, time.Time) {})
+}
+
+// simulate replays a recorded game on a fresh room, passing emit each
+// message seat would have been sent, and settles it as the engine would.
+func simulate(rep *models.GameReplay, seat int, emit func(ws.MessageType, interface{}, time.Time)) (*models.Settlement, error) {
+	g := &rep.Game
+	if g.Seats < models.MinSeats || g.Seats > models.MaxSeats || len(g.Players) != g.Seats {
+		return nil, fmt.Errorf("game %s has %d players for %d seats", g.GameID, len(g.Players), g.Seats)
+	}
+	if seat < -1 || seat >= g.Seats {
+		return nil, fmt.Errorf("game %s has no seat %d", g.GameID, seat)
+	}
+	if g.FirstSeat < 0 || g.FirstSeat >= g.Seats {
+		return nil, fmt.Errorf("game %s has no first seat %d", g.GameID, g.FirstSeat)
+	}
+
+	room := models.NewRoom(g.RoomID, "", g.AnteAmount)
+	room.GameID = g.GameID
+	room.Seats = g.Seats
+	room.Rules = g.Rules
+	room.CurrentTurn = g.FirstSeat
+	room.Phase = models.PhasePlaying
+	if g.Rules.Game == models.GameSamLoc {
+		room.Phase = models.PhaseDeclaring
+	}
+	if g.OpeningCard != nil {
+		card := *g.OpeningCard
+		room.OpeningCard = &card
+	}
+	room.Deck = g.Deck
+	for _, p := range g.Players {
+		if p.Seat < 0 || p.Seat >= g.Seats || room.Players[p.Seat] != nil {
+			return nil, fmt.Errorf("game %s has a bad seat %d", g.GameID, p.Seat)
+		}
+		hand := make([]models.Card, len(p.Hand))
+		copy(hand, p.Hand)
+		room.Players[p.Seat] = &models.Player{
+			UserID:    p.UserID,
+			Username:  p.Username,
+			SeatIndex: p.Seat,
+			IsBot:     p.IsBot,
+			Hand:      hand,
+			CardCount: len(hand),
+		}
+	}
+
+	turn := func(at time.Time) {
+		emit(ws.MsgTurnChange, turnPayload(room, false), at)
+	}
+
+	dealt := buildGameStateForPlayer(room, seat)
+	dealt.ReplayOf = g.GameID
+	if seat >= 0 {
+		emit(ws.MsgCardDealt, dealt, g.StartedAt)
+	} else {
+		emit(ws.MsgGameState, dealt, g.StartedAt)
+	}
+
+	var result *models.Settlement
+	if winner, kind, ok := FindInstantWin(room, g.FirstSeat); ok {
+		result = buildInstantWinSettlement(room, winner, kind)
+	}
+
+	for _, m := range rep.Moves {
+		if result != nil {
+			return nil, fmt.Errorf("move %d: the game was already over", m.Seq)
+		}
+		if m.Seat < 0 || m.Seat >= g.Seats {
+			return nil, fmt.Errorf("move %d: no seat %d", m.Seq, m.Seat)
+		}
+
+		if m.Action == models.MoveDeclareSam {
+			if room.Phase != models.PhaseDeclaring {
+				return nil, fmt.Errorf("move %d: declarations are closed", m.Seq)
+			}
+			room.Phase = models.PhasePlaying
+			room.SamDeclarer = m.Seat
+			room.CurrentTurn = m.Seat
+			room.OpeningCard = nil
+			emit(ws.MsgSamDeclared, map[string]interface{}{"player_index": m.Seat}, m.At)
+			turn(m.At)
+			continue
+		}
+		// Nobody declared: play started when the window closed.
+		if room.Phase == models.PhaseDeclaring {
+			room.Phase = models.PhasePlaying
+			turn(m.At)
+		}
+		if m.Seat != room.CurrentTurn {
+			return nil, fmt.Errorf("move %d: seat %d moved on seat %d's turn", m.Seq, m.Seat, room.CurrentTurn)
+		}
+
+		switch m.Action {
+		case models.MovePlay:
+			combo, err := validatePlay(room, m.Seat, m.Cards)
+			if err != nil {
+				return nil, fmt.Errorf("move %d: %v", m.Seq, err)
+			}
+			chop := findChop(room, m.Seat, m.Cards, combo)
+			p := room.Players[m.Seat]
+			room.OpeningCard = nil
+			p.Hand = models.RemoveCards(p.Hand, m.Cards)
+			p.CardCount = len(p.Hand)
+			room.TablePlay = &models.TablePlay{PlayerIndex: m.Seat, Cards: m.Cards, ComboType: combo}
+			emit(ws.MsgMovePlayed, map[string]interface{}{
+				"player_index": m.Seat,
+				"cards":        m.Cards,
+				"combo_type":   combo,
+			}, m.At)
+
+			room.LastChop = nil
+			if chop != nil {
+				chargeChop(room, chop)
+				emit(ws.MsgChop, chop, m.At)
+			}
+
+			if result = settleIfOver(room, m.Seat); result != nil {
+				continue
+			}
+		case models.MovePass, models.MoveTimeout:
+			if room.TablePlay == nil || room.TablePlay.PlayerIndex == m.Seat {
+				return nil, fmt.Errorf("move %d: seat %d cannot pass here", m.Seq, m.Seat)
+			}
+			room.PassCount++
+			room.RoundPassed[m.Seat] = true
+			nextTurn(room)
+			payload := turnPayload(room, false)
+			payload["action"] = m.Action
+			payload["player_index"] = m.Seat
+			emit(ws.MsgTurnChange, payload, m.At)
+			continue
+		default:
+			return nil, fmt.Errorf("move %d: unknown action %q", m.Seq, m.Action)
+		}
+		nextTurn(room)
+		turn(m.At)
+	}
+
+	if result != nil {
+		settleChops(room, result)
+		result.Deck = g.Deck
+	}
+	return result, nil
+}
+
+// settleIfOver applies the end-of-game rules after seat idx played and
+// returns the settlement if the game is over: a sâm ends when anyone beats
+// the declarer or they go out, a full-ranking game once one player still
+// holds cards, and any other game when the player goes out.
+func settleIfOver(room *models.Room, idx int) *models.Settlement {
+	out := room.Players[idx].CardCount == 0
+	switch {
+	case room.SamDeclarer >= 0 && (idx != room.SamDeclarer || out):
+		return buildSamSettlement(room, idx)
+	case !out:
+		return nil
+	case !room.Rules.FullRanking || room.Rules.Game == models.GameSamLoc:
+		return buildSettlement(room, idx)
+	case finishPlace(room, idx):
+		return buildRankedSettlement(room)
+	}
+	return nil
+}
+
+// replayStream is a replay being sent to one client.
+type replayStream struct {
+	mu      sync.Mutex
+	stopped bool
+	timer   clock.Timer
+}
+
+func (s *replayStream) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+// handleWatchReplay streams a finished game to a client outside any room,
+// paced as it was played and sped up or slowed down by the requested
+// speed. The client watches from its own seat if it played, otherwise from
+// the seat asked for, or as a spectator.
+func (e *Engine) handleWatchReplay(client *ws.Client, payload json.RawMessage) {
+	var p ws.WatchReplayPayload
+	if err := json.Unmarshal(payload, &p); err != nil || p.GameID == "" {
+		client.Deliver(ws.NewErrorMessage("invalid watch_replay payload"))
+		return
+	}
+	if client.GetRoom() > 0 {
+		client.Deliver(ws.NewErrorMessage("leave the room to watch a replay"))
+		return
+	}
+	if e.history == nil {
+		client.Deliver(ws.NewErrorMessage("replays are not available"))
+		return
+	}
+	speed := p.Speed
+	if speed == 0 {
+		speed = 1
+	}
+	speed = min(max(speed, minReplaySpeed), maxReplaySpeed)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), historyTimeout)
+		rep, err := e.history.GetReplay(ctx, p.GameID)
+		cancel()
+		if err != nil {
+			log.Printf("replay %s: load failed: %v", p.GameID, err)
+			client.Deliver(ws.NewErrorMessage("could not load the replay"))
+			return
+		}
+		if rep == nil {
+			client.Deliver(ws.NewErrorMessage("game not found"))
+			return
+		}
+		if rep.Status == models.GameInProgress {
+			client.Deliver(ws.NewErrorMessage("game is still in progress"))
+			return
+		}
+
+		// Players see their own hand and admins the seat they ask for;
+		// anyone else watches as a spectator.
+		seat := -1
+		if p.Seat != nil && e.admins.Has(client.UserID) {
+			seat = *p.Seat
+		}
+		for _, pl := range rep.Game.Players {
+			if pl.UserID == client.UserID {
+				seat = pl.Seat
+			}
+		}
+		events, err := BuildReplay(rep, seat)
+		if err != nil {
+			log.Printf("replay %s: %v", p.GameID, err)
+			client.Deliver(ws.NewErrorMessage("the replay could not be built"))
+			return
+		}
+		if client.GetRoom() > 0 {
+			return // joined a room while the game loaded
+		}
+		e.streamReplay(client, events, speed)
+	}()
+}
+
+// streamReplay sends events to client, replacing any replay it is already
+// watching.
+func (e *Engine) streamReplay(client *ws.Client, events []ReplayEvent, speed float64) {
+	s := &replayStream{}
+	e.replayMu.Lock()
+	if old := e.replays[client.UserID]; old != nil {
+		old.stop()
+	}
+	e.replays[client.UserID] = s
+	e.replayMu.Unlock()
+
+	var step func(i int)
+	step = func(i int) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.stopped {
+			return
+		}
+		data, _ := ws.NewMessage(events[i].Type, events[i].Payload)
+		client.Deliver(data)
+		if i+1 == len(events) {
+			s.stopped = true
+			e.forgetReplay(client.UserID, s)
+			return
+		}
+		gap := min(max(events[i+1].At.Sub(events[i].At), 0), maxReplayGap)
+		s.timer = e.clock.AfterFunc(time.Duration(float64(gap)/speed), func() { step(i + 1) })
+	}
+	step(0)
+}
+
+// stopReplay stops whatever replay the user is watching.
+func (e *Engine) stopReplay(userID int64) {
+	e.replayMu.Lock()
+	s := e.replays[userID]
+	delete(e.replays, userID)
+	e.replayMu.Unlock()
+	if s != nil {
+		s.stop()
+	}
+}
+
+func (e *Engine) forgetReplay(userID int64, s *replayStream) {
+	e.replayMu.Lock()
+	defer e.replayMu.Unlock()
+	if e.replays[userID] == s {
+		delete(e.replays, userID)
+	}
+}
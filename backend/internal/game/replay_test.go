@@ -0,0 +1,342 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/game-playzui/tienlen-server/internal/auth"
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/ws"
+)
+
+// replayGame is a two-seat game seat 0 wins in three leads, with a pause
+// before each move.
+const replayGame = `
+table seats=2
+` + twoSeatDeal + `
+wait 2s
+play 0 3S 4S 5S 6S 7S 8S 9S 10S JS QS KS
+wait 2s
+pass 1
+wait 2s
+play 0 3C
+wait 2s
+pass 1
+wait 2s
+play 0 2S
+`
+
+// playRecorded plays replayGame and returns its replay along with what
+// seat 0 was sent after the deal.
+func playRecorded(t *testing.T) (*harness, *models.GameReplay, []ws.Message) {
+	t.Helper()
+	store := newMemHistory()
+	h := newHarness(t, store)
+	h.run(replayGame)
+	id := store.waitEnded(t)
+
+	h.next(h.seats[0]) // card_dealt
+	var live []ws.Message
+	for {
+		msg, ok := h.next(h.seats[0])
+		if !ok {
+			t.Fatal("no settlement")
+		}
+		live = append(live, msg)
+		if msg.Type == ws.MsgSettlement {
+			break
+		}
+	}
+
+	rep, err := store.GetReplay(context.Background(), id)
+	if err != nil || rep == nil || rep.Status != models.GameFinished {
+		t.Fatalf("GetReplay = %+v, %v", rep, err)
+	}
+	return h, rep, live
+}
+
+func TestBuildReplayMatchesLiveGame(t *testing.T) {
+	_, rep, live := playRecorded(t)
+
+	events, err := BuildReplay(rep, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dealt, ok := events[0].Payload.(GameStatePayload)
+	if events[0].Type != ws.MsgCardDealt || !ok {
+		t.Fatalf("first event = %+v", events[0])
+	}
+	if dealt.ReplayOf != rep.Game.GameID || len(dealt.Hand) != 13 || dealt.CurrentTurn != 0 {
+		t.Errorf("deal = %+v", dealt)
+	}
+
+	// Everything replayed after the deal is what the seat saw live, less
+	// the turn deadlines.
+	events = events[1:]
+	if len(events) != len(live) {
+		t.Fatalf("replayed %d events, live game sent %d: %s", len(events), len(live), describe(live))
+	}
+	for i, ev := range events {
+		data, _ := json.Marshal(ev.Payload)
+		var want, got interface{}
+		json.Unmarshal(data, &want)
+		json.Unmarshal(live[i].Payload, &got)
+		if ev.Type != live[i].Type || !matches(want, got) {
+			t.Errorf("event %d = %s %s, live %s %s", i, ev.Type, data, live[i].Type, live[i].Payload)
+		}
+	}
+	if at := events[0].At; !at.Equal(time.Unix(2, 0)) {
+		t.Errorf("first move at %v, want 2s in", at)
+	}
+}
+
+func TestBuildReplayHidesHandsFromSpectators(t *testing.T) {
+	_, rep, _ := playRecorded(t)
+
+	events, err := BuildReplay(rep, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, _ := events[0].Payload.(GameStatePayload)
+	if events[0].Type != ws.MsgGameState || len(state.Hand) != 0 {
+		t.Errorf("first event = %s %+v", events[0].Type, state)
+	}
+}
+
+func TestBuildReplayRejectsIllegalMoves(t *testing.T) {
+	_, rep, _ := playRecorded(t)
+
+	for name, tamper := range map[string]func(*models.GameReplay){
+		"card not held": func(r *models.GameReplay) { r.Moves[0].Cards = parseCards(t, "4C") },
+		"out of turn":   func(r *models.GameReplay) { r.Moves[1].Seat = 0 },
+		"after the end": func(r *models.GameReplay) {
+			r.Moves = append(r.Moves, models.GameMove{Seq: 6, Seat: 1, Action: models.MovePass})
+		},
+	} {
+		r := *rep
+		r.Moves = append([]models.GameMove{}, rep.Moves...)
+		tamper(&r)
+		if _, err := BuildReplay(&r, 0); err == nil {
+			t.Errorf("%s: replay built", name)
+		}
+	}
+}
+
+func TestWatchReplayStreamsAtSpeed(t *testing.T) {
+	h, rep, _ := playRecorded(t)
+	viewer := h.newClient(300, "viewer")
+
+	h.send(viewer, ws.MsgWatchReplay, ws.WatchReplayPayload{GameID: rep.Game.GameID, Speed: 2})
+	// The deal, then a move and turn change or a pass for each of the five
+	// moves, then the settlement.
+	want := []ws.MessageType{
+		ws.MsgGameState,
+		ws.MsgMovePlayed, ws.MsgTurnChange,
+		ws.MsgTurnChange,
+		ws.MsgMovePlayed, ws.MsgTurnChange,
+		ws.MsgTurnChange,
+		ws.MsgMovePlayed,
+		ws.MsgSettlement,
+	}
+	start := h.clock.Now()
+	var got []ws.Message
+	var at []time.Duration
+	for tries := 0; len(got) < len(want) && tries < 1000; tries++ {
+		select {
+		case data := <-viewer.Send:
+			got = append(got, h.decode(data))
+			at = append(at, h.clock.Now().Sub(start))
+		case <-time.After(5 * time.Millisecond):
+			h.clock.Advance(100 * time.Millisecond)
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %s, want %d messages", describe(got), len(want))
+	}
+	for i, msg := range got {
+		if msg.Type != want[i] {
+			t.Fatalf("message %d is %s, want %s: %s", i, msg.Type, want[i], describe(got))
+		}
+	}
+	var deal GameStatePayload
+	json.Unmarshal(got[0].Payload, &deal)
+	if deal.ReplayOf != rep.Game.GameID || len(deal.Hand) != 0 {
+		t.Errorf("deal = %s", got[0].Payload)
+	}
+	// Moves were 2s apart; at double speed they come 1s apart.
+	if at[7] < 5*time.Second {
+		t.Errorf("last move replayed after %v, want at least 5s", at[7])
+	}
+}
+
+// watchReplay asks to watch the recorded game as c and returns the first
+// and last messages of the replay: the deal and the settlement.
+func watchReplay(h *harness, c *ws.Client, req ws.WatchReplayPayload) (deal, settlement ws.Message) {
+	h.t.Helper()
+	req.Speed = 8
+	h.send(c, ws.MsgWatchReplay, req)
+	for tries := 0; tries < 1000; tries++ {
+		select {
+		case data := <-c.Send:
+			msg := h.decode(data)
+			if deal.Type == "" {
+				deal = msg
+			}
+			if msg.Type == ws.MsgSettlement {
+				return deal, msg
+			}
+		case <-time.After(5 * time.Millisecond):
+			h.clock.Advance(time.Second)
+		}
+	}
+	h.t.Fatalf("the replay did not finish; it began with %s", deal.Type)
+	return
+}
+
+// Only the game's players see their hands in a replay, and only admins may
+// ask to see someone else's; anyone else gets the spectator view, without
+// the seeds the hands could be dealt again from.
+func TestWatchReplayShowsHandsOnlyToPlayersAndAdmins(t *testing.T) {
+	h, rep, _ := playRecorded(t)
+	h.engine.SetAdmins(auth.NewAdmins([]int{400}))
+	h.send(h.seats[1], ws.MsgLeaveRoom, nil)
+	h.pending(h.seats[1])
+	seat := 0
+	req := ws.WatchReplayPayload{GameID: rep.Game.GameID, Seat: &seat}
+
+	for _, tt := range []struct {
+		who   *ws.Client
+		hand  int
+		seeds bool
+	}{
+		{h.newClient(300, "stranger"), 0, false},
+		{h.newClient(400, "admin"), 13, true},
+		{h.seats[1], 13, true},
+	} {
+		deal, settlement := watchReplay(h, tt.who, req)
+		var state GameStatePayload
+		json.Unmarshal(deal.Payload, &state)
+		var result models.Settlement
+		json.Unmarshal(settlement.Payload, &result)
+		if len(state.Hand) != tt.hand {
+			t.Errorf("%s saw a hand of %d cards, want %d", tt.who.Username, len(state.Hand), tt.hand)
+		}
+		if result.Deck == nil || result.Deck.SeedHash == "" || (result.Deck.ServerSeed != "") != tt.seeds {
+			t.Errorf("%s saw deck %+v, want seeds %v", tt.who.Username, result.Deck, tt.seeds)
+		}
+	}
+}
+
+func TestWatchReplayRequiresLeavingTheRoom(t *testing.T) {
+	h, rep, _ := playRecorded(t)
+
+	h.send(h.seats[0], ws.MsgWatchReplay, ws.WatchReplayPayload{GameID: rep.Game.GameID})
+	msg, ok := h.next(h.seats[0])
+	for ok && msg.Type != ws.MsgError {
+		msg, ok = h.next(h.seats[0])
+	}
+	if !ok {
+		t.Fatal("watch_replay from inside a room was not refused")
+	}
+}
+
+func TestBuildReplayEndsBlockedSam(t *testing.T) {
+	rep, err := models.ParseGame(`
+[Game "sam_loc"]
+[Variant "sam_loc"]
+[FirstSeat "1"]
+[Hand0 "3S 4S 5S 6S 7S 9S 9D KS KD 2S"]
+[Hand1 "3C 4C 5C 6C 7C 8C JD JH AD 2H"]
+
+1. 0: declare_sam
+2. 0: 3S 4S 5S 6S 7S
+3. 1: 4C 5C 6C 7C 8C
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := BuildReplay(rep, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []ws.MessageType
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	want := []ws.MessageType{ws.MsgCardDealt, ws.MsgSamDeclared, ws.MsgTurnChange, ws.MsgMovePlayed, ws.MsgTurnChange, ws.MsgMovePlayed}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("events = %v, want %v", types, want)
+	}
+	if turn := events[2].Payload.(map[string]interface{}); turn["current_turn"] != 0 {
+		t.Errorf("declarer does not lead: %v", turn)
+	}
+
+	rep.Moves = append(rep.Moves, models.GameMove{Seq: 4, Seat: 0, Action: models.MovePass})
+	if _, err := BuildReplay(rep, 1); err == nil {
+		t.Error("replay went on after the sâm was blocked")
+	}
+}
+
+func TestSimulateGameMatchesEngine(t *testing.T) {
+	for name, script := range map[string]string{
+		"cards out": replayGame,
+		"chop": `
+table seats=2
+` + twoSeatDeal + `
+play 0 3S
+pass 1
+play 0 2S
+play 1 4C 4D 5C 5D 6C 6D
+pass 0
+play 1 8H 9H 10H JH QH KH AH
+`,
+		"instant win": `
+table seats=2
+deal 3S 3C 4S 5S 6S 7S 8S 9S 10S JS QS KS 4C | 2S 2C 2D 2H AS AC AD AH 5C 6C 7C 8C 9C
+`,
+		"ranked": `
+table seats=3 ranking
+deal 3S 4S 5S 6S 7S 8S 9S 10S JS QS KS 2S 2C | 3C 3D 4C 4D 5C 6C 7C 8C 9C 10C JC QC KC | 3H 5D 6D 7D 8D 9D 10D JD QD KD AD 2D AH
+play 0 3S 4S 5S 6S 7S 8S 9S 10S JS QS KS
+play 1 3C 4C 5C 6C 7C 8C 9C 10C JC QC KC
+pass 2
+pass 0
+play 1 3D
+pass 2
+pass 0
+play 1 4D
+pass 2
+pass 0
+play 2 5D 6D 7D 8D 9D 10D JD QD KD AD
+pass 0
+play 2 3H
+play 0 2C
+pass 2
+play 0 2S
+`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := newMemHistory()
+			newHarness(t, store).run(script)
+			id := store.waitEnded(t)
+
+			rep, _ := store.GetReplay(context.Background(), id)
+			if rep.Settlement == nil {
+				t.Fatal("game was not settled")
+			}
+			got, err := SimulateGame(rep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, rep.Settlement) {
+				gotJSON, _ := json.Marshal(got)
+				wantJSON, _ := json.Marshal(rep.Settlement)
+				t.Errorf("simulated %s\nengine    %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
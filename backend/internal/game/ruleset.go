@@ -0,0 +1,71 @@
+package game
+
+import (
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+// RuleSet is one variant of Tien Len. A room plays by the rule set named in
+// its RoomRules.Variant; per-table options such as which instant wins are
+// honoured stay in RoomRules.
+type RuleSet interface {
+	Variant() models.Variant
+
+	// Classify determines the type of a set of cards, sorting them in place.
+	Classify(cards []models.Card) (models.CombinationType, bool)
+	// CanBeat reports whether play beats the table. A nil table means the
+	// play leads a new round.
+	CanBeat(table *models.TablePlay, play []models.Card, playCombo models.CombinationType) bool
+
+	// BombRank orders the combinations that can chop 2s; 0 means the
+	// combination is not a bomb.
+	BombRank(combo models.CombinationType, n int) int
+	// PigValue is what chopped 2s are worth in antes.
+	PigValue(twos []models.Card) int
+
+	// OpeningCard is held by the player who leads a table's opening game,
+	// and their first play must include it. If it is not dealt, the lowest
+	// card dealt takes its place.
+	OpeningCard() models.Card
+	// IsInstantWin reports whether a dealt hand qualifies as kind.
+	IsInstantWin(hand []models.Card, kind models.InstantWin) bool
+	// LossMultiplier is how many antes a loser left holding hand pays.
+	LossMultiplier(hand []models.Card, cardCount int) int
+	// MaxLossMultiplier is the most antes one player can lose in a game at
+	// a table of seats players. It sizes the escrow and caps chops.
+	MaxLossMultiplier(seats int) int
+
+	// HandSize is the number of cards dealt to each player.
+	HandSize() int
+}
+
+var ruleSets = map[models.Variant]RuleSet{}
+
+func init() {
+	RegisterRuleSet(MienNam{})
+	RegisterRuleSet(MienBac{})
+	RegisterRuleSet(SamLoc{})
+}
+
+// RegisterRuleSet makes a rule set available to rooms. It is not safe for
+// concurrent use and should be called during initialisation.
+func RegisterRuleSet(rs RuleSet) {
+	ruleSets[rs.Variant()] = rs
+}
+
+// LookupRuleSet returns the rule set registered for v.
+func LookupRuleSet(v models.Variant) (RuleSet, bool) {
+	rs, ok := ruleSets[v]
+	return rs, ok
+}
+
+// RulesFor returns the rule set a room plays by, falling back to Mien Nam
+// for an unknown variant.
+func RulesFor(room *models.Room) RuleSet {
+	if room.Rules.Game == models.GameSamLoc {
+		return SamLoc{}
+	}
+	if rs, ok := ruleSets[room.Rules.Variant]; ok {
+		return rs
+	}
+	return MienNam{}
+}
@@ -0,0 +1,297 @@
+package game
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/ws"
+)
+
+const (
+	samHandSize = 10
+
+	// declareTimeout is how long players have to báo sâm after the deal.
+	declareTimeout = 10 * time.Second
+
+	// samStake is what a sâm is worth per opponent, in antes: each opponent
+	// pays it to a declarer who goes out unbeaten, and a declarer who is
+	// beaten pays it to each opponent.
+	samStake = 20
+
+	// samFullHandPenalty is what a loser who never played a card (cóng)
+	// pays, in antes. Other losers pay one ante per card left.
+	samFullHandPenalty = 20
+
+	// samPigValue is what each chopped 2 is worth, in antes.
+	samPigValue = 5
+)
+
+// SamLoc is the rule set for Sâm Lốc: ten-card hands, suits never matter,
+// no double sequences, and only four-of-a-kind chops a 2.
+type SamLoc struct{}
+
+func (SamLoc) Variant() models.Variant { return models.VariantSamLoc }
+
+func (SamLoc) Classify(cards []models.Card) (models.CombinationType, bool) {
+	combo, ok := ClassifyCombination(cards)
+	if !ok || combo == models.ComboDoubleSequence {
+		return 0, false
+	}
+	return combo, true
+}
+
+// CanBeat compares ranks only: a play must be the same kind and size as the
+// table and rank strictly higher.
+func (SamLoc) CanBeat(table *models.TablePlay, play []models.Card, playCombo models.CombinationType) bool {
+	if table == nil {
+		return true
+	}
+	if table.ComboType == models.ComboSingle && table.Cards[0].Rank == models.Two &&
+		playCombo == models.ComboFourOfAKind {
+		return true
+	}
+	if playCombo != table.ComboType || len(play) != len(table.Cards) {
+		return false
+	}
+	return highRank(play) > highRank(table.Cards)
+}
+
+func (SamLoc) BombRank(combo models.CombinationType, n int) int {
+	if combo == models.ComboFourOfAKind {
+		return 1
+	}
+	return 0
+}
+
+func (SamLoc) PigValue(twos []models.Card) int {
+	return samPigValue * len(twos)
+}
+
+func (SamLoc) OpeningCard() models.Card {
+	return models.ThreeOfSpades()
+}
+
+func (SamLoc) IsInstantWin(hand []models.Card, kind models.InstantWin) bool {
+	var counts [13]int
+	for _, c := range hand {
+		counts[c.Rank]++
+	}
+
+	switch kind {
+	case models.InstantWinSamDragon:
+		run := 0
+		for r := models.Three; r <= models.Ace; r++ {
+			if counts[r] == 1 {
+				run++
+				if run == 10 {
+					return true
+				}
+			} else {
+				run = 0
+			}
+		}
+		return false
+	case models.InstantWinFourTwos:
+		return counts[models.Two] == 4
+	case models.InstantWinFivePairs:
+		pairs := 0
+		for _, n := range counts {
+			pairs += n / 2
+		}
+		return pairs >= 5
+	case models.InstantWinThreeTriples:
+		triples := 0
+		for _, n := range counts {
+			if n >= 3 {
+				triples++
+			}
+		}
+		return triples >= 3
+	case models.InstantWinOneColour:
+		red := redCount(hand)
+		return red == 0 || red == len(hand)
+	}
+	return false
+}
+
+func (SamLoc) LossMultiplier(hand []models.Card, cardCount int) int {
+	if cardCount == samHandSize {
+		return samFullHandPenalty
+	}
+	return cardCount
+}
+
+func (SamLoc) MaxLossMultiplier(seats int) int {
+	return samStake * (seats - 1)
+}
+
+func (SamLoc) HandSize() int {
+	return samHandSize
+}
+
+func highRank(cards []models.Card) models.Rank {
+	high := cards[0].Rank
+	for _, c := range cards[1:] {
+		if c.Rank > high {
+			high = c.Rank
+		}
+	}
+	return high
+}
+
+// startDeclaring opens the báo sâm window after a Sâm Lốc deal. Bots and
+// absent players never declare; if nobody else can, play starts at once.
+// Must be called on the room's goroutine.
+func (e *Engine) startDeclaring(room *models.Room) {
+	room.SamPending = [4]bool{}
+	pending := false
+	for i := 0; i < room.Seats; i++ {
+		if p := room.Players[i]; p != nil && !p.IsBot && !p.AutoPlay && !p.Disconnected {
+			room.SamPending[i] = true
+			pending = true
+		}
+	}
+	if !pending {
+		e.finishDeclaring(room)
+		return
+	}
+
+	roomID, gameID := room.ID, room.GameID
+	e.sched.schedule(roomID, declareTimeout, func(gen uint64) {
+		e.hub.Do(roomID, func(r *models.Room) {
+			if e.sched.fired(roomID, gen) && r.Phase == models.PhaseDeclaring && r.GameID == gameID {
+				e.finishDeclaring(r)
+			}
+		})
+	})
+}
+
+func (e *Engine) handleDeclareSam(client *ws.Client, room *models.Room, payload json.RawMessage) {
+	var p ws.DeclareSamPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		client.Deliver(ws.NewErrorMessage("invalid declare_sam payload"))
+		return
+	}
+
+	if room.Phase != models.PhaseDeclaring {
+		client.Deliver(ws.NewErrorMessage("declarations are closed"))
+		return
+	}
+	idx, _ := room.FindPlayerByUserID(client.UserID)
+	if idx < 0 || !room.SamPending[idx] {
+		client.Deliver(ws.NewErrorMessage("you cannot declare now"))
+		return
+	}
+
+	if p.Declare {
+		// The first player to declare takes the sâm.
+		room.SamPending[idx] = false
+		room.SamDeclarer = idx
+		e.recordMove(room, idx, models.MoveDeclareSam, nil)
+		e.finishDeclaring(room)
+		return
+	}
+	e.withdrawDeclaration(room, idx)
+}
+
+// withdrawDeclaration records that seat idx will not declare, and starts
+// play once nobody else can.
+// Must be called on the room's goroutine.
+func (e *Engine) withdrawDeclaration(room *models.Room, idx int) {
+	room.SamPending[idx] = false
+	for _, pending := range room.SamPending {
+		if pending {
+			return
+		}
+	}
+	e.finishDeclaring(room)
+}
+
+// finishDeclaring closes the báo sâm window and starts play. A declarer
+// leads the first round with anything they like.
+// Must be called on the room's goroutine.
+func (e *Engine) finishDeclaring(room *models.Room) {
+	e.cancelTurnTimer(room.ID)
+	room.SamPending = [4]bool{}
+	room.Phase = models.PhasePlaying
+
+	if room.SamDeclarer >= 0 {
+		room.CurrentTurn = room.SamDeclarer
+		room.OpeningCard = nil
+		log.Printf("room %d: seat %d declared sâm", room.ID, room.SamDeclarer)
+
+		data, _ := ws.NewMessage(ws.MsgSamDeclared, map[string]interface{}{
+			"player_index": room.SamDeclarer,
+		})
+		e.hub.Broadcast(room, data)
+	}
+
+	e.startTurnTimer(room)
+	e.broadcastTurn(room, false)
+}
+
+// endSam settles a game in which a player declared sâm.
+// Must be called on the room's goroutine.
+func (e *Engine) endSam(room *models.Room, winnerIdx int) {
+	e.finishGame(room, buildSamSettlement(room, winnerIdx))
+}
+
+// buildSamSettlement settles a sâm. If the declarer went out unbeaten,
+// every opponent pays them samStake antes; if winnerIdx beat them, the
+// declarer pays samStake antes to every opponent. Payments are capped at
+// each payer's escrow.
+func buildSamSettlement(room *models.Room, winnerIdx int) *models.Settlement {
+	declarer := room.SamDeclarer
+	settlement := &models.Settlement{
+		GameID:      room.GameID,
+		RoomID:      room.ID,
+		Winner:      winnerIdx,
+		Reason:      models.SettleSam,
+		Results:     make([]*models.SettlementResult, room.Seats),
+		SamDeclarer: &declarer,
+	}
+	if winnerIdx != declarer {
+		settlement.Reason = models.SettleSamBlocked
+	}
+
+	var headroom [4]int
+	for i := 0; i < room.Seats; i++ {
+		p := room.Players[i]
+		if p == nil {
+			continue
+		}
+		headroom[i] = lossHeadroom(room, i)
+		settlement.Results[i] = &models.SettlementResult{
+			Seat:      i,
+			UserID:    p.UserID,
+			Username:  p.Username,
+			CardsLeft: p.CardCount,
+			TwosHeld:  countTwos(p.Hand),
+			IsBot:     p.IsBot,
+		}
+	}
+
+	for i, opponent := range settlement.Results {
+		if opponent == nil || i == declarer {
+			continue
+		}
+		payer, payee := opponent, settlement.Results[declarer]
+		if settlement.Reason == models.SettleSamBlocked {
+			payer, payee = payee, payer
+		}
+
+		pays := min(samStake*room.AnteAmount, headroom[payer.Seat])
+		headroom[payer.Seat] -= pays
+		fee := pays / 10
+
+		payer.PenaltyMultiplier = samStake
+		payer.GoldDelta -= pays
+		payee.GoldDelta += pays - fee
+		payee.Fee += fee
+		settlement.TotalPot += pays
+		settlement.ServerFee += fee
+	}
+	return settlement
+}
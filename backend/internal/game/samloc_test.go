@@ -0,0 +1,159 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+func TestSamLocClassify(t *testing.T) {
+	tests := []struct {
+		cards string
+		combo models.CombinationType
+		ok    bool
+	}{
+		{"7H", models.ComboSingle, true},
+		{"2D", models.ComboSingle, true},
+		{"7S 7H", models.ComboPair, true},
+		{"2S 2H", models.ComboPair, true},
+		{"9S 9C 9H", models.ComboTriple, true},
+		{"JS JC JD JH", models.ComboFourOfAKind, true},
+		{"3S 4S 5S", models.ComboSequence, true},
+		{"5D 6D 7D 8D 9D 10D JD QD KD AD", models.ComboSequence, true},
+		{"3S 4C 5S", models.ComboSequence, true},
+		{"8H 9H 10D JH", models.ComboSequence, true},
+		{"KS AS 2S", 0, false},
+		{"QD KD AD 2D", 0, false},
+		{"3S 3C 4S 4C 5S 5C", 0, false},
+		{"3S 5S", 0, false},
+	}
+	for _, tt := range tests {
+		combo, ok := SamLoc{}.Classify(parseCards(t, tt.cards))
+		if ok != tt.ok || (ok && combo != tt.combo) {
+			t.Errorf("Classify(%s) = %v, %v; want %v, %v", tt.cards, combo, ok, tt.combo, tt.ok)
+		}
+	}
+}
+
+func TestSamLocCanBeat(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		play  string
+		want  bool
+	}{
+		{"lead", "", "3S", true},
+		{"single higher rank", "5H", "9S", true},
+		{"single lower rank", "9S", "5H", false},
+		{"single same rank higher suit", "9S", "9H", false},
+		{"two on ace", "AH", "2S", true},
+		{"two on two", "2S", "2H", false},
+		{"pair higher", "5S 5C", "8D 8H", true},
+		{"pair same rank", "5S 5C", "5D 5H", false},
+		{"triple higher", "5S 5C 5H", "8S 8D 8H", true},
+		{"sequence higher other suit", "3S 4S 5S", "4H 5H 6H", true},
+		{"sequence of mixed suits", "3S 4C 5S", "4H 5D 6S", true},
+		{"sequence same top rank", "4S 5S 6S", "4H 5H 6H", false},
+		{"sequence length differs", "3S 4S 5S", "4S 5S 6S 7S", false},
+		{"pair on single", "5S", "8D 8H", false},
+		{"four chops single two", "2H", "6S 6C 6D 6H", true},
+		{"four does not chop pair of twos", "2S 2H", "6S 6C 6D 6H", false},
+		{"four over four", "6S 6C 6D 6H", "9S 9C 9D 9H", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := SamLoc{}
+			var table *models.TablePlay
+			if tt.table != "" {
+				cards := parseCards(t, tt.table)
+				combo, ok := rules.Classify(cards)
+				if !ok {
+					t.Fatalf("table %s is not a valid play", tt.table)
+				}
+				table = &models.TablePlay{Cards: cards, ComboType: combo}
+			}
+			play := parseCards(t, tt.play)
+			combo, ok := rules.Classify(play)
+			if !ok {
+				t.Fatalf("play %s is not a valid play", tt.play)
+			}
+			if got := rules.CanBeat(table, play, combo); got != tt.want {
+				t.Errorf("CanBeat(%s, %s) = %v, want %v", tt.table, tt.play, got, tt.want)
+			}
+		})
+	}
+}
+
+// samDeal gives seat 0 a hand it can go out with unbeaten.
+const samDeal = `deal 3S 4S 5S 6S 7S 9C 9D KH AH 2D | 3C 4D 5H 6C 8D 8H 10C JD QH 5C`
+
+func TestScenarioSamDeclared(t *testing.T) {
+	runScenario(t, `
+table seats=2 game=sam_loc
+`+samDeal+`
+see * card_dealt {"phase":"DECLARING"}
+send 0 declare_sam {"declare":true}
+see * sam_declared {"player_index":0}
+see * turn_change {"current_turn":0,"opening_card":null}
+
+# The declarer leads without the 3 of spades rule and plays out unbeaten.
+play 0 9C 9D
+pass 1
+play 0 3S 4S 5S 6S 7S
+pass 1
+play 0 KH
+pass 1
+play 0 AH
+pass 1
+play 0 2D
+drain *
+see * settlement {"winner":0,"reason":"sam","sam_declarer":0,"results":[{"seat":0,"gold_delta":1800,"fee":200},{"seat":1,"cards_left":10,"penalty_multiplier":20,"gold_delta":-2000}]}
+`)
+}
+
+func TestScenarioSamBlocked(t *testing.T) {
+	runScenario(t, `
+table seats=2 game=sam_loc
+deal 3S 4S 5S 6S 7S 9C 9D KH AH 2D | 3C 4D 5H 6C 8D 8H 10C JD AD 5C
+see * card_dealt
+send 1 declare_sam {"declare":false}
+send 0 declare_sam {"declare":true}
+drain *
+
+# Beating the declarer ends the game at once.
+play 0 KH
+play 1 AD
+drain *
+see * settlement {"winner":1,"reason":"sam_blocked","sam_declarer":0,"results":[{"seat":0,"cards_left":9,"penalty_multiplier":20,"gold_delta":-2000},{"seat":1,"gold_delta":1800,"fee":200}]}
+`)
+}
+
+func TestScenarioSamLocSettlement(t *testing.T) {
+	runScenario(t, `
+table seats=3 game=sam_loc
+deal 3S 4S 5S 6S 7S 9C 9D KH AH 2D | 3C 4D 5H 6C 8D 8H 10C JD QH 5C | 3D 4H 6D 7C 10D JH AC KC 10H 6H
+see * card_dealt {"phase":"DECLARING"}
+
+# Nobody declares before the window closes.
+wait 10s
+see * turn_change {"current_turn":0}
+play 0 3S 4S 5S 6S 7S
+pass 1
+pass 2
+play 0 9C 9D
+pass 1
+pass 2
+play 0 KH
+pass 1
+play 2 AC
+play 0 2D
+pass 1
+pass 2
+play 0 AH
+drain *
+
+# Seat 1 never played a card and pays the full-hand penalty; seat 2 pays
+# an ante per card left.
+see * settlement {"winner":0,"reason":"cards_out","results":[{"seat":0,"gold_delta":2610,"fee":290},{"seat":1,"cards_left":10,"penalty_multiplier":20,"gold_delta":-2000},{"seat":2,"cards_left":9,"penalty_multiplier":9,"gold_delta":-900}]}
+`)
+}
@@ -0,0 +1,268 @@
+package game
+
+import "testing"
+
+// Hands for two-seat scenarios. Seat 0 holds the 3 of spades and a 2 of
+// spades to lead into a chop; seat 1 holds three consecutive pairs.
+const twoSeatDeal = `deal 3S 3C 4S 5S 6S 7S 8S 9S 10S JS QS KS 2S | 4C 4D 5C 5D 6C 6D 8H 9H 10H JH QH KH AH`
+
+func TestScenarioOpeningLead(t *testing.T) {
+	runScenario(t, `
+table seats=2
+`+twoSeatDeal+`
+see 0 card_dealt {"current_turn":0,"deadline":30000,"opening_card":{"rank":"3","suit":"S"}}
+see 1 card_dealt {"current_turn":0,"hand":[{"rank":"4","suit":"C"},{"rank":"4","suit":"D"},{"rank":"5","suit":"C"},{"rank":"5","suit":"D"},{"rank":"6","suit":"C"},{"rank":"6","suit":"D"},{"rank":"8","suit":"H"},{"rank":"9","suit":"H"},{"rank":"10","suit":"H"},{"rank":"J","suit":"H"},{"rank":"Q","suit":"H"},{"rank":"K","suit":"H"},{"rank":"A","suit":"H"}]}
+
+# Only the player who erred hears about it.
+play 1 4C
+see 1 error {"error":"not your turn"}
+play 0 4S
+see 0 error {"error":"the first play must include the 3 of spades"}
+pass 0
+see 0 error {"error":"you must play cards to start the round"}
+quiet *
+
+play 0 3S
+see * move_played {"player_index":0,"cards":[{"rank":"3","suit":"S"}],"combo_type":0}
+see * turn_change {"current_turn":1,"table_clear":false,"opening_card":null}
+pass 1
+see * turn_change {"action":"pass","player_index":1,"current_turn":0,"table_clear":true}
+quiet *
+`)
+}
+
+func TestScenarioTimeBankThenTimeout(t *testing.T) {
+	runScenario(t, `
+table seats=2 timer=10 bank=5
+`+twoSeatDeal+`
+see * card_dealt {"deadline":10000,"turn_timer":10,"players":[{"time_bank_ms":5000},{"time_bank_ms":5000}]}
+
+play 0 3S 3C
+see * move_played
+see * turn_change {"current_turn":1,"deadline":10000}
+
+# Seat 1 runs out of turn time, then out of bank, and is passed for.
+timeout
+see * turn_change {"current_turn":1,"time_bank":true,"deadline":15000}
+quiet *
+timeout
+see * turn_change {"action":"timeout","player_index":1,"current_turn":0,"table_clear":true,"deadline":25000}
+
+# Seat 0 leads nothing in time and has their lowest card played for them.
+wait 10s
+see * turn_change {"current_turn":0,"time_bank":true,"deadline":30000}
+timeout
+see * move_played {"player_index":0,"cards":[{"rank":"4","suit":"S"}]}
+see * turn_change {"current_turn":1,"deadline":40000}
+quiet *
+`)
+}
+
+func TestScenarioChop(t *testing.T) {
+	runScenario(t, `
+table seats=2
+`+twoSeatDeal+`
+drain *
+play 0 3S
+pass 1
+drain *
+
+play 0 2S
+see * move_played
+see * turn_change {"current_turn":1}
+play 1 4C 4D 5C 5D 6C 6D
+see * move_played {"player_index":1,"combo_type":4}
+see * chop {"chopper":1,"chopped":0,"chopped_cards":[{"rank":"2","suit":"S"}],"chain":1,"value":100,"amount":100}
+see * turn_change {"current_turn":0,"table_clear":false}
+quiet *
+`)
+}
+
+func TestScenarioSpectator(t *testing.T) {
+	runScenario(t, `
+table seats=2
+`+twoSeatDeal+`
+drain *
+watch
+see s0 room_update {"phase":"PLAYING","current_turn":0,"hand":null}
+quiet *
+
+play 0 3S
+see 0,1,s0 move_played {"player_index":0}
+see * turn_change {"current_turn":1}
+send s0 chat {"message":"hi"}
+see * chat_relay {"message":"hi","sender":"s0"}
+quiet *
+`)
+}
+
+func TestScenarioInstantWin(t *testing.T) {
+	runScenario(t, `
+table seats=2
+deal 3S 3C 4S 5S 6S 7S 8S 9S 10S JS QS KS 4C | 2S 2C 2D 2H AS AC AD AH 5C 6C 7C 8C 9C
+see * card_dealt
+see * settlement {"winner":1,"reason":"instant_win","instant_win":"four_twos","results":[{"seat":0,"cards_left":13,"penalty_multiplier":3,"gold_delta":-300},{"seat":1,"gold_delta":270,"fee":30}]}
+quiet *
+
+wait 5s
+see * room_update {"phase":"LOBBY"}
+quiet *
+
+# The winner leads the next game without an opening card.
+deal
+see * card_dealt {"current_turn":1,"opening_card":null}
+`)
+}
+
+// Hands for three-seat scenarios. Seat 0 holds the 3 of spades.
+const threeSeatDeal = `deal 3S 4S 5H 6S 7H 8S 9H 10S JH QS KH 2S 2H | 3C 3H 4C 5C 6C 7C 9C 10C JC QC KC AC AS | 4D 5D 6D 7D 8C 8D 9D 10D JD QD KD AD 2D`
+
+func TestScenarioRoundLock(t *testing.T) {
+	runScenario(t, `
+table seats=3
+`+threeSeatDeal+`
+drain *
+play 0 3S
+see * move_played
+see * turn_change {"current_turn":1}
+pass 1
+see * turn_change {"action":"pass","player_index":1,"current_turn":2,"table_clear":false}
+play 2 4D
+see * move_played
+see * turn_change {"current_turn":0}
+
+# Seat 1 passed this round, so the turn goes past them.
+play 0 5H
+see * move_played
+see * turn_change {"current_turn":2}
+watch
+see s0 room_update {"current_turn":2,"passed":[1]}
+quiet *
+
+# Once only seat 0 is left, the round clears and seat 1 is back in.
+pass 2
+see * turn_change {"action":"pass","player_index":2,"current_turn":0,"table_clear":true}
+play 0 6S
+see * move_played
+see * turn_change {"current_turn":1}
+quiet *
+`)
+}
+
+func TestScenarioFullRanking(t *testing.T) {
+	runScenario(t, `
+table seats=3 ranking
+deal 3S 4S 5S 6S 7S 8S 9S 10S JS JC JD 2S 2C | 3C 4C 5C 6C 7C 8C 9C 10C QS QC QD 2H AS | 3D 4D 5D 6D 7D 8D 9D 10D KS KC KD AD 3H
+drain *
+play 0 3S 4S 5S 6S 7S 8S 9S 10S
+pass 1
+pass 2
+play 0 JS JC JD
+pass 1
+pass 2
+drain *
+play 0 2S 2C
+see * move_played
+# Seat 0 is out in first place and play goes on without them.
+see * turn_change {"current_turn":1,"table_clear":false}
+pass 1
+pass 2
+see * turn_change
+see * turn_change {"current_turn":1,"table_clear":true}
+watch
+see s0 room_update {"finish_order":[0]}
+quiet *
+play 1 3C 4C 5C 6C 7C 8C 9C 10C
+pass 2
+play 1 QS QC QD
+pass 2
+play 1 2H
+pass 2
+drain *
+
+# Seat 1 takes second place, which leaves seat 2 last. Last pays first and
+# second neither pays nor is paid.
+play 1 AS
+see * move_played
+see * settlement {"winner":0,"reason":"ranked","finish_order":[0,1,2],"results":[{"position":1,"gold_delta":270,"fee":30},{"position":2,"gold_delta":0},{"position":3,"cards_left":13,"penalty_multiplier":3,"gold_delta":-300}]}
+quiet *
+`)
+}
+
+func TestScenarioWinnerLeadsNextGame(t *testing.T) {
+	runScenario(t, `
+table seats=2
+`+twoSeatDeal+`
+drain *
+play 0 3S 3C
+pass 1
+play 0 4S 5S 6S 7S 8S 9S 10S JS QS KS
+pass 1
+drain *
+play 0 2S
+see * move_played
+see * settlement {"winner":0}
+wait 5s
+see * room_update {"phase":"LOBBY"}
+quiet *
+
+# Seat 1 now holds the 3 of spades, but seat 0 won and leads with anything.
+deal 4C 4D 5C 5D 6C 6D 8H 9H 10H JH QH KH AH | 3S 3C 4S 5S 6S 7S 8S 9S 10S JS QS KS 2S
+see * card_dealt {"current_turn":0,"opening_card":null}
+play 0 8H 9H 10H
+see * move_played {"player_index":0,"combo_type":3}
+see * turn_change {"current_turn":1,"table_clear":false}
+quiet *
+`)
+}
+
+func TestScenarioThreeSeats(t *testing.T) {
+	runScenario(t, `
+table seats=3
+`+threeSeatDeal+`
+see * card_dealt {"current_turn":0,"players":[{"card_count":13},{"card_count":13},{"card_count":13}]}
+play 0 3S 4S 5H 6S 7H 8S 9H 10S JH QS KH
+see * move_played
+see * turn_change {"current_turn":1}
+pass 1
+see * turn_change {"action":"pass","player_index":1,"current_turn":2}
+pass 2
+see * turn_change {"action":"pass","player_index":2,"current_turn":0,"table_clear":true}
+quiet *
+
+# Both losers pay the winner.
+play 0 2S 2H
+see * move_played
+see * settlement {"winner":0,"reason":"cards_out","total_pot":600,"results":[{"seat":0,"gold_delta":540,"fee":60},{"seat":1,"penalty_multiplier":3,"gold_delta":-300},{"seat":2,"penalty_multiplier":3,"gold_delta":-300}]}
+quiet *
+`)
+}
+
+// A time bank is spent only as far as it is used, and what is left carries
+// over to the player's later turns.
+func TestScenarioTimeBankCarriesOver(t *testing.T) {
+	runScenario(t, `
+table seats=2 timer=10 bank=10
+`+twoSeatDeal+`
+drain *
+play 0 3S
+see * move_played
+see * turn_change {"current_turn":1,"deadline":10000}
+timeout
+see * turn_change {"current_turn":1,"time_bank":true,"deadline":20000}
+wait 3s
+play 1 4C
+see * move_played
+see * turn_change {"current_turn":0,"deadline":23000}
+watch
+see s0 room_update {"players":[{"time_bank_ms":10000},{"time_bank_ms":7000}]}
+quiet *
+
+play 0 5S
+see * move_played
+see * turn_change {"current_turn":1,"deadline":23000}
+timeout
+see * turn_change {"current_turn":1,"time_bank":true,"deadline":30000}
+quiet *
+`)
+}
@@ -0,0 +1,74 @@
+package game
+
+import (
+	"sync"
+	"time"
+
+	"github.com/game-playzui/tienlen-server/internal/clock"
+)
+
+// scheduler owns each room's single pending deadline: the turn timer, the
+// báo sâm window or the return to the lobby after settlement. Arming a
+// deadline replaces the room's previous one and gives it a new generation.
+// A callback that was already on its way when its deadline was cancelled or
+// replaced finds its generation stale and must do nothing. It is safe for
+// concurrent use.
+type scheduler struct {
+	clock clock.Clock
+
+	mu    sync.Mutex
+	gen   uint64
+	slots map[int]slot
+}
+
+type slot struct {
+	gen   uint64
+	timer clock.Timer
+}
+
+func newScheduler(c clock.Clock) *scheduler {
+	return &scheduler{clock: c, slots: make(map[int]slot)}
+}
+
+// schedule arms roomID's deadline d from now, replacing any pending one. fn
+// is called with the new generation once the deadline passes; it should
+// hand off to the room's goroutine and claim the deadline with fired there
+// before acting.
+func (s *scheduler) schedule(roomID int, d time.Duration, fn func(gen uint64)) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.slots[roomID]; ok {
+		old.timer.Stop()
+	}
+	s.gen++
+	gen := s.gen
+	s.slots[roomID] = slot{gen: gen, timer: s.clock.AfterFunc(d, func() { fn(gen) })}
+	return gen
+}
+
+// cancel drops roomID's pending deadline, if any.
+func (s *scheduler) cancel(roomID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.slots[roomID]; ok {
+		old.timer.Stop()
+		delete(s.slots, roomID)
+	}
+}
+
+// fired claims gen's deadline for its callback: it reports whether gen was
+// still current and, if so, clears the slot so the deadline cannot fire
+// twice.
+func (s *scheduler) fired(roomID int, gen uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sl, ok := s.slots[roomID]
+	if !ok || sl.gen != gen {
+		return false
+	}
+	delete(s.slots, roomID)
+	return true
+}
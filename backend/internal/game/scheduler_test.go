@@ -0,0 +1,80 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/game-playzui/tienlen-server/internal/clock"
+)
+
+func TestSchedulerFiresOnDeadline(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	s := newScheduler(c)
+
+	var got []uint64
+	gen := s.schedule(1, 10*time.Second, func(gen uint64) {
+		if s.fired(1, gen) {
+			got = append(got, gen)
+		}
+	})
+
+	c.Advance(9 * time.Second)
+	if len(got) != 0 {
+		t.Fatalf("fired early: %v", got)
+	}
+	c.Advance(time.Second)
+	if len(got) != 1 || got[0] != gen {
+		t.Fatalf("got %v, want [%d]", got, gen)
+	}
+	if s.fired(1, gen) {
+		t.Error("deadline could be claimed twice")
+	}
+}
+
+func TestSchedulerReplaceAndCancel(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	s := newScheduler(c)
+
+	fired := map[int]int{}
+	fn := func(room int) func(uint64) {
+		return func(gen uint64) {
+			if s.fired(room, gen) {
+				fired[room]++
+			}
+		}
+	}
+
+	s.schedule(1, 5*time.Second, fn(1))
+	s.schedule(1, 20*time.Second, fn(1))
+	s.schedule(2, 5*time.Second, fn(2))
+	s.cancel(2)
+
+	c.Advance(10 * time.Second)
+	if fired[1] != 0 || fired[2] != 0 {
+		t.Fatalf("replaced or cancelled deadline fired: %v", fired)
+	}
+	c.Advance(10 * time.Second)
+	if fired[1] != 1 || fired[2] != 0 {
+		t.Fatalf("fired = %v, want room 1 once", fired)
+	}
+}
+
+// A callback that was already running when its deadline was replaced must
+// see a stale generation.
+func TestSchedulerStaleGeneration(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	s := newScheduler(c)
+
+	var stale bool
+	s.schedule(1, time.Second, func(gen uint64) {
+		s.schedule(1, time.Minute, func(uint64) {})
+		stale = !s.fired(1, gen)
+	})
+	c.Advance(time.Second)
+	if !stale {
+		t.Error("callback claimed a deadline that had been replaced")
+	}
+	if c.Pending() != 1 {
+		t.Errorf("pending = %d, want the replacement deadline", c.Pending())
+	}
+}
@@ -0,0 +1,401 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/ws"
+)
+
+// GoldStore reserves and books player gold.
+// ApplySettlement must be atomic, book any of Settlement.Chops not booked
+// yet before the rest, release the game's escrow, and be idempotent per
+// Settlement.GameID. ApplyChop must be atomic, take the
+// payment out of the payer's escrow, and be idempotent per game and chop
+// index.
+type GoldStore interface {
+	AvailableGold(ctx context.Context, userID int64) (int64, error)
+	ReserveEscrow(ctx context.Context, gameID string, holds []models.EscrowHold) error
+	ReleaseEscrow(ctx context.Context, gameID string) error
+	ApplySettlement(ctx context.Context, s *models.Settlement) error
+	ApplyChop(ctx context.Context, p *models.ChopPayment) error
+}
+
+// maxLoss is the most gold one player can lose in a game at this table.
+func maxLoss(room *models.Room) int {
+	return room.AnteAmount * RulesFor(room).MaxLossMultiplier(room.Seats)
+}
+
+// checkCanCoverAnte returns a user-facing error if the player's available
+// gold cannot cover the worst-case loss at this table.
+// Must be called on the room's goroutine.
+func (e *Engine) checkCanCoverAnte(room *models.Room, userID int64) error {
+	if e.gold == nil {
+		return nil
+	}
+	required := int64(maxLoss(room))
+	ctx, cancel := context.WithTimeout(context.Background(), goldQueryTimeout)
+	defer cancel()
+	available, err := e.gold.AvailableGold(ctx, userID)
+	if err != nil {
+		log.Printf("balance check for user %d failed: %v", userID, err)
+		return errors.New("could not verify your gold balance, try again")
+	}
+	if available < required {
+		return insufficientGoldError(required, available)
+	}
+	return nil
+}
+
+func insufficientGoldError(required, available int64) error {
+	return fmt.Errorf("insufficient gold: this table needs %dG available, you have %dG", required, available)
+}
+
+// reserveEscrow holds the worst-case loss from every human player before the
+// deal. On failure the offending player (or everyone, if the store itself
+// failed) is un-readied and the game does not start.
+// Must be called on the room's goroutine.
+func (e *Engine) reserveEscrow(room *models.Room) bool {
+	if e.gold == nil {
+		return true
+	}
+	required := int64(maxLoss(room))
+	holds := make([]models.EscrowHold, 0, room.Seats)
+	for _, p := range room.Players {
+		if p != nil && !p.IsBot {
+			holds = append(holds, models.EscrowHold{UserID: p.UserID, Amount: required})
+		}
+	}
+	if len(holds) == 0 {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), goldQueryTimeout)
+	err := e.gold.ReserveEscrow(ctx, room.GameID, holds)
+	cancel()
+	if err == nil {
+		return true
+	}
+
+	var short *models.InsufficientGoldError
+	if errors.As(err, &short) {
+		if _, p := room.FindPlayerByUserID(short.UserID); p != nil {
+			p.IsReady = false
+			e.hub.SendToClient(p.UserID, ws.NewErrorMessage(insufficientGoldError(short.Required, short.Available).Error()))
+		}
+	} else {
+		log.Printf("room %d: escrow for game %s failed: %v", room.ID, room.GameID, err)
+		for _, p := range room.Players {
+			if p != nil {
+				p.IsReady = false
+			}
+		}
+		e.hub.Broadcast(room, ws.NewErrorMessage("could not start the game, please ready up again"))
+	}
+
+	data, _ := ws.NewMessage(ws.MsgRoomUpdate, room.ToInfo())
+	e.hub.Broadcast(room, data)
+	return false
+}
+
+func (e *Engine) releaseEscrow(gameID string) {
+	if e.gold == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), settlementTimeout)
+	defer cancel()
+	if err := e.gold.ReleaseEscrow(ctx, gameID); err != nil {
+		log.Printf("release escrow for game %s failed: %v", gameID, err)
+	}
+}
+
+// countTwos returns how many 2s are in the hand.
+func countTwos(hand []models.Card) int {
+	count := 0
+	for _, c := range hand {
+		if c.Rank == models.Two {
+			count++
+		}
+	}
+	return count
+}
+
+// deadPigMultiplier returns the penalty multiplier for a loser.
+// Extended rules: holding any 2 = 2x, 13 cards (never played) = 3x, all four 2s = 4x.
+// Highest applicable multiplier wins (they don't stack).
+func deadPigMultiplier(hand []models.Card, cardCount int) int {
+	twos := countTwos(hand)
+	if twos == 4 {
+		return 4
+	}
+	if cardCount == 13 {
+		return 3
+	}
+	if twos > 0 {
+		return 2
+	}
+	return 1
+}
+
+// endGame must be called on the room's goroutine
+func (e *Engine) endGame(room *models.Room) {
+	winnerIdx := -1
+	for i, p := range room.Players {
+		if p != nil && p.CardCount == 0 {
+			winnerIdx = i
+			break
+		}
+	}
+
+	e.finishGame(room, buildSettlement(room, winnerIdx))
+}
+
+// endInstantWin ends the game straight after the deal.
+// Must be called on the room's goroutine.
+func (e *Engine) endInstantWin(room *models.Room, winnerIdx int, kind models.InstantWin) {
+	log.Printf("room %d: seat %d wins instantly with %s", room.ID, winnerIdx, kind)
+	e.finishGame(room, buildInstantWinSettlement(room, winnerIdx, kind))
+}
+
+// buildInstantWinSettlement settles a game won on the deal. Every loser
+// still holds all 13 cards, so the usual dead-pig multipliers apply. The
+// winning hand is revealed to the table.
+func buildInstantWinSettlement(room *models.Room, winnerIdx int, kind models.InstantWin) *models.Settlement {
+	settlement := buildSettlement(room, winnerIdx)
+	settlement.Reason = models.SettleInstantWin
+	settlement.InstantWin = kind
+	settlement.WinningHand = room.Players[winnerIdx].Hand
+	return settlement
+}
+
+// finishGame moves the room into settlement and books the result.
+// Must be called on the room's goroutine.
+func (e *Engine) finishGame(room *models.Room, settlement *models.Settlement) {
+	e.cancelTurnTimer(room.ID)
+	room.Phase = models.PhaseSettlement
+	room.Winner = settlement.Winner
+	room.GamesPlayed++
+	room.LastWinnerID = room.Players[settlement.Winner].UserID
+	settleChops(room, settlement)
+	settlement.Deck = room.Deck
+
+	log.Printf("room %d settlement: game=%s winner=seat%d pot=%d fee=%d",
+		room.ID, room.GameID, settlement.Winner, settlement.TotalPot, settlement.ServerFee)
+
+	go e.commitSettlement(settlement)
+}
+
+// buildSettlement computes every seat's gold delta for a game won by
+// winnerIdx. Chops are added when the game is finished.
+func buildSettlement(room *models.Room, winnerIdx int) *models.Settlement {
+	ante := room.AnteAmount
+	rules := RulesFor(room)
+	settlement := &models.Settlement{
+		GameID:  room.GameID,
+		RoomID:  room.ID,
+		Winner:  winnerIdx,
+		Reason:  models.SettleCardsOut,
+		Results: make([]*models.SettlementResult, room.Seats),
+	}
+
+	totalPot := 0
+	for i := 0; i < room.Seats; i++ {
+		p := room.Players[i]
+		if p == nil {
+			continue
+		}
+		if i == winnerIdx {
+			continue
+		}
+		multiplier := rules.LossMultiplier(p.Hand, p.CardCount)
+		loserPays := min(ante*multiplier, lossHeadroom(room, i))
+		totalPot += loserPays
+
+		settlement.Results[i] = &models.SettlementResult{
+			Seat:              i,
+			UserID:            p.UserID,
+			Username:          p.Username,
+			CardsLeft:         p.CardCount,
+			TwosHeld:          countTwos(p.Hand),
+			PenaltyMultiplier: multiplier,
+			GoldDelta:         -loserPays,
+			IsBot:             p.IsBot,
+		}
+	}
+
+	serverFee := totalPot / 10
+	winnerReceives := totalPot - serverFee
+
+	if wp := room.Players[winnerIdx]; wp != nil {
+		settlement.Results[winnerIdx] = &models.SettlementResult{
+			Seat:      winnerIdx,
+			UserID:    wp.UserID,
+			Username:  wp.Username,
+			GoldDelta: winnerReceives,
+			IsBot:     wp.IsBot,
+			Fee:       serverFee,
+		}
+	}
+
+	settlement.ServerFee = serverFee
+	settlement.TotalPot = totalPot
+	return settlement
+}
+
+// endRankedGame settles a full-ranking game by finishing place.
+// Must be called on the room's goroutine.
+func (e *Engine) endRankedGame(room *models.Room) {
+	e.finishGame(room, buildRankedSettlement(room))
+}
+
+// buildRankedSettlement settles a full-ranking game by finishing place.
+// Places are paired from the outside in: last pays first, second-to-last
+// pays second, and so on, with the outer pairs paying more. The last
+// player, who is still holding cards, pays at least the rule set's loss
+// multiplier for their hand.
+func buildRankedSettlement(room *models.Room) *models.Settlement {
+	order := room.FinishOrder
+	n := len(order)
+	ante := room.AnteAmount
+	rules := RulesFor(room)
+	settlement := &models.Settlement{
+		GameID:      room.GameID,
+		RoomID:      room.ID,
+		Winner:      order[0],
+		Reason:      models.SettleRanked,
+		Results:     make([]*models.SettlementResult, room.Seats),
+		FinishOrder: order,
+	}
+
+	for place, seat := range order {
+		p := room.Players[seat]
+		settlement.Results[seat] = &models.SettlementResult{
+			Seat:      seat,
+			UserID:    p.UserID,
+			Username:  p.Username,
+			CardsLeft: p.CardCount,
+			TwosHeld:  countTwos(p.Hand),
+			IsBot:     p.IsBot,
+			Position:  place + 1,
+		}
+	}
+
+	for k := 0; k < n/2; k++ {
+		winner := settlement.Results[order[k]]
+		loser := settlement.Results[order[n-1-k]]
+
+		multiplier := n/2 - k
+		if k == 0 {
+			lp := room.Players[loser.Seat]
+			if m := rules.LossMultiplier(lp.Hand, lp.CardCount); m > multiplier {
+				multiplier = m
+			}
+		}
+		pays := min(ante*multiplier, lossHeadroom(room, loser.Seat))
+		fee := pays / 10
+
+		loser.PenaltyMultiplier = multiplier
+		loser.GoldDelta -= pays
+		winner.GoldDelta += pays - fee
+		winner.Fee += fee
+		settlement.TotalPot += pays
+		settlement.ServerFee += fee
+	}
+	return settlement
+}
+
+// commitSettlement books the settlement and only then announces it to the
+// room, so clients never see gold that was not actually persisted, then
+// schedules the room's return to the lobby. It runs on its own goroutine
+// because it waits on the database, and failed attempts are retried on the
+// engine's clock. The game's chops are booked first.
+func (e *Engine) commitSettlement(s *models.Settlement) {
+	if e.gold == nil {
+		e.announceSettlement(s, 0, nil)
+		return
+	}
+	booked, failed := e.waitChops(s.GameID)
+	what := fmt.Sprintf("room %d: settlement for game %s", s.RoomID, s.GameID)
+	e.retry(what, func(ctx context.Context) error {
+		return e.gold.ApplySettlement(ctx, s)
+	}, func(err error) {
+		if err != nil {
+			for _, index := range failed {
+				log.Printf("room %d: chop %d of game %s was never booked and needs reconciling", s.RoomID, index, s.GameID)
+			}
+			e.releaseEscrow(s.GameID)
+		}
+		e.announceSettlement(s, booked, err)
+	})
+}
+
+// retry runs op until it succeeds or has failed settlementAttempts times,
+// waiting a second longer on the engine's clock after each failure, then
+// passes done the last error. done is not called on the room's goroutine.
+func (e *Engine) retry(what string, op func(ctx context.Context) error, done func(error)) {
+	var attempt func(n int)
+	attempt = func(n int) {
+		ctx, cancel := context.WithTimeout(context.Background(), settlementTimeout)
+		err := op(ctx)
+		cancel()
+		if err == nil || n == settlementAttempts {
+			if err != nil {
+				log.Printf("%s: giving up after %d attempts: %v", what, n, err)
+			}
+			done(err)
+			return
+		}
+		log.Printf("%s: attempt %d failed: %v", what, n, err)
+		e.clock.AfterFunc(time.Duration(n)*time.Second, func() { attempt(n + 1) })
+	}
+	attempt(1)
+}
+
+// announceSettlement records how a game was booked and tells the table,
+// then schedules the room's return to the lobby. chopsBooked is how many of
+// the game's chops were paid before the settlement, which stand even if it
+// failed.
+func (e *Engine) announceSettlement(s *models.Settlement, chopsBooked int, err error) {
+	if err != nil {
+		e.recordAbort(s.GameID)
+	} else {
+		e.recordResult(s)
+	}
+
+	e.hub.Do(s.RoomID, func(room *models.Room) {
+		if room.GameID != s.GameID || room.Phase != models.PhaseSettlement {
+			return
+		}
+		if err != nil {
+			e.hub.Broadcast(room, ws.NewErrorMessage(settlementFailedMessage(chopsBooked)))
+		} else {
+			data, _ := ws.NewMessage(ws.MsgSettlement, s)
+			e.hub.Broadcast(room, data)
+		}
+
+		e.sched.schedule(room.ID, settlementResetDelay, func(gen uint64) {
+			e.hub.Do(room.ID, func(room *models.Room) {
+				if e.sched.fired(room.ID, gen) && room.GameID == s.GameID && room.Phase == models.PhaseSettlement {
+					e.resetRoom(room)
+				}
+			})
+		})
+	})
+}
+
+// settlementFailedMessage tells the table what gold moved in a game whose
+// settlement could not be booked.
+func settlementFailedMessage(chopsBooked int) string {
+	switch chopsBooked {
+	case 0:
+		return "settlement failed, no gold was transferred"
+	case 1:
+		return "settlement failed, only 1 chop was paid"
+	default:
+		return fmt.Sprintf("settlement failed, only %d chops were paid", chopsBooked)
+	}
+}
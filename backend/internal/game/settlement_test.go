@@ -0,0 +1,401 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/ws"
+)
+
+// memGold is a GoldStore whose first failures settlements fail, and whose
+// first chopFailures chops. A settlement waits for gate, if it is set,
+// before it is booked. Users missing from available have plenty of gold.
+type memGold struct {
+	mu           sync.Mutex
+	gate         chan struct{}
+	available    map[int64]int64
+	holds        []models.EscrowHold
+	failures     int
+	chopFailures int
+	chopTries    int
+	settled      []*models.Settlement
+	released     []string
+	attempts     chan int
+	chopAttempts chan int
+	chops        chan models.ChopPayment
+}
+
+func newMemGold(failures int) *memGold {
+	return &memGold{
+		failures:     failures,
+		attempts:     make(chan int, 16),
+		chopAttempts: make(chan int, 16),
+		chops:        make(chan models.ChopPayment, 16),
+	}
+}
+
+func (g *memGold) AvailableGold(_ context.Context, userID int64) (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if n, ok := g.available[userID]; ok {
+		return n, nil
+	}
+	return 1 << 40, nil
+}
+
+func (g *memGold) ReserveEscrow(ctx context.Context, _ string, holds []models.EscrowHold) error {
+	for _, hold := range holds {
+		if n, _ := g.AvailableGold(ctx, hold.UserID); n < hold.Amount {
+			return &models.InsufficientGoldError{UserID: hold.UserID, Available: n, Required: hold.Amount}
+		}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.holds = append(g.holds, holds...)
+	return nil
+}
+
+func (g *memGold) ReleaseEscrow(_ context.Context, gameID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.released = append(g.released, gameID)
+	return nil
+}
+
+func (g *memGold) ApplySettlement(_ context.Context, s *models.Settlement) error {
+	if g.gate != nil {
+		<-g.gate
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := len(g.settled) + 1
+	defer func() { g.attempts <- n }()
+	if g.failures > 0 {
+		g.failures--
+		g.settled = append(g.settled, nil)
+		return errors.New("database is down")
+	}
+	g.settled = append(g.settled, s)
+	return nil
+}
+
+func (g *memGold) ApplyChop(_ context.Context, p *models.ChopPayment) error {
+	g.mu.Lock()
+	g.chopTries++
+	n, fail := g.chopTries, g.chopFailures > 0
+	if fail {
+		g.chopFailures--
+	}
+	g.mu.Unlock()
+	g.chopAttempts <- n
+	if fail {
+		return errors.New("database is down")
+	}
+	g.chops <- *p
+	return nil
+}
+
+// waitAttempt waits for settlement attempt n.
+func (g *memGold) waitAttempt(t *testing.T, n int) {
+	t.Helper()
+	select {
+	case got := <-g.attempts:
+		if got != n {
+			t.Fatalf("settlement attempt %d, want %d", got, n)
+		}
+	case <-time.After(messageWait):
+		t.Fatalf("no settlement attempt %d", n)
+	}
+}
+
+// settlementMessage returns the next settlement or error sent to c.
+func settlementMessage(h *harness, c *ws.Client) (ws.Message, bool) {
+	for {
+		msg, ok := h.next(c)
+		if !ok || msg.Type == ws.MsgSettlement || msg.Type == ws.MsgError {
+			return msg, ok
+		}
+	}
+}
+
+// A player who cannot cover the worst loss (ante × 4) cannot ready; once
+// they can, the game holds that much from every player until it ends.
+func TestReadyNeedsTheEscrow(t *testing.T) {
+	h := newHarness(t, nil)
+	gold := newMemGold(0)
+	gold.available = map[int64]int64{101: 399}
+	h.engine.gold = gold
+	h.run(`
+table seats=2
+`)
+	h.join(h.seats[0])
+	h.join(h.seats[1])
+	h.run("drain *")
+	h.send(h.seats[1], ws.MsgReady, nil)
+	h.run(`
+see 1 error {"error":"insufficient gold: this table needs 400G available, you have 399G"}
+`)
+
+	gold.mu.Lock()
+	gold.available[101] = 400
+	gold.mu.Unlock()
+	h.run(`
+drain *
+` + twoSeatDeal + `
+see * card_dealt
+leave 0
+`)
+	gold.mu.Lock()
+	want := []models.EscrowHold{{UserID: 100, Amount: 400}, {UserID: 101, Amount: 400}}
+	if !reflect.DeepEqual(gold.holds, want) {
+		t.Errorf("holds = %+v, want %+v", gold.holds, want)
+	}
+	gold.mu.Unlock()
+
+	// Leaving aborts the game, which releases the holds.
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		gold.mu.Lock()
+		released := len(gold.released)
+		gold.mu.Unlock()
+		if released == 1 {
+			break
+		}
+		if time.Since(start) > messageWait {
+			t.Fatal("escrow was not released when the game was aborted")
+		}
+	}
+}
+
+func TestSettlementRetriesOnTheClock(t *testing.T) {
+	h := newHarness(t, nil)
+	gold := newMemGold(2)
+	h.engine.gold = gold
+	h.run(replayGame)
+
+	gold.waitAttempt(t, 1)
+	h.advance(time.Second - time.Millisecond)
+	select {
+	case n := <-gold.attempts:
+		t.Fatalf("attempt %d came before the backoff", n)
+	default:
+	}
+	h.advance(time.Millisecond)
+	gold.waitAttempt(t, 2)
+	h.advance(2 * time.Second)
+	gold.waitAttempt(t, 3)
+
+	if msg, ok := settlementMessage(h, h.seats[0]); !ok || msg.Type != ws.MsgSettlement {
+		t.Fatalf("got %s after the third attempt, want the settlement", msg.Type)
+	}
+}
+
+// The table hears the settlement only once its gold is booked, and hears
+// the same deltas that were booked.
+func TestSettlementIsAnnouncedOnceBooked(t *testing.T) {
+	h := newHarness(t, nil)
+	gold := newMemGold(0)
+	gold.gate = make(chan struct{})
+	h.engine.gold = gold
+	h.run(replayGame)
+
+	h.advance(time.Second)
+	for _, msg := range h.pending(h.seats[0]) {
+		if msg.Type == ws.MsgSettlement {
+			t.Fatal("settlement announced before it was booked")
+		}
+	}
+	close(gold.gate)
+	gold.waitAttempt(t, 1)
+	msg, ok := settlementMessage(h, h.seats[0])
+	if !ok || msg.Type != ws.MsgSettlement {
+		t.Fatalf("got %s once booked, want the settlement", msg.Type)
+	}
+
+	var announced models.Settlement
+	if err := json.Unmarshal(msg.Payload, &announced); err != nil {
+		t.Fatal(err)
+	}
+	gold.mu.Lock()
+	defer gold.mu.Unlock()
+	booked := gold.settled[0]
+	if announced.GameID != booked.GameID || len(announced.Results) != len(booked.Results) {
+		t.Fatalf("announced %+v, booked %+v", announced, booked)
+	}
+	net := announced.ServerFee
+	for i, r := range announced.Results {
+		if r.GoldDelta != booked.Results[i].GoldDelta {
+			t.Errorf("seat %d: announced %d, booked %d", i, r.GoldDelta, booked.Results[i].GoldDelta)
+		}
+		net += r.GoldDelta
+	}
+	if net != 0 {
+		t.Errorf("deltas and fee add up to %d, want 0", net)
+	}
+}
+
+func TestSettlementGivesUpWithoutWaiting(t *testing.T) {
+	h := newHarness(t, nil)
+	gold := newMemGold(settlementAttempts)
+	h.engine.gold = gold
+	h.run(replayGame)
+
+	gold.waitAttempt(t, 1)
+	h.advance(time.Second)
+	gold.waitAttempt(t, 2)
+	h.advance(2 * time.Second)
+	gold.waitAttempt(t, 3)
+
+	// The table hears at once; nothing waits out a backoff after the last
+	// attempt.
+	if msg, ok := settlementMessage(h, h.seats[0]); !ok || msg.Type != ws.MsgError {
+		t.Fatalf("got %s after the last attempt, want an error", msg.Type)
+	}
+	gold.mu.Lock()
+	defer gold.mu.Unlock()
+	if len(gold.released) != 1 {
+		t.Errorf("escrow released for %v", gold.released)
+	}
+}
+
+func TestChopIsPaidWhenMade(t *testing.T) {
+	h := newHarness(t, nil)
+	gold := newMemGold(0)
+	h.engine.gold = gold
+	h.run(`
+table seats=2
+` + twoSeatDeal + `
+play 0 3S
+pass 1
+play 0 2S
+play 1 4C 4D 5C 5D 6C 6D
+`)
+
+	var paid models.ChopPayment
+	select {
+	case paid = <-gold.chops:
+	case <-time.After(messageWait):
+		t.Fatal("the chop was not paid")
+	}
+	want := models.ChopPayment{GameID: paid.GameID, RoomID: harnessRoomID, Index: 0, FromUserID: 100, ToUserID: 101, Amount: 100}
+	if paid.GameID == "" || paid != want {
+		t.Errorf("paid %+v, want %+v", paid, want)
+	}
+
+	// Aborting the game releases the escrow; the chop stays paid.
+	h.send(h.seats[0], ws.MsgLeaveRoom, nil)
+	for deadline := time.Now().Add(messageWait); ; time.Sleep(time.Millisecond) {
+		gold.mu.Lock()
+		settled, released := len(gold.settled), len(gold.released)
+		gold.mu.Unlock()
+		if settled != 0 {
+			t.Fatal("an aborted game was settled")
+		}
+		if released == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("escrow was not released")
+		}
+	}
+}
+
+func TestChopIsCappedAtEscrow(t *testing.T) {
+	h := newHarness(t, nil)
+	gold := newMemGold(0)
+	h.engine.gold = gold
+
+	// Two red 2s chopped by four consecutive pairs are worth 4 × 3 antes,
+	// but seat 0 only has 4 antes in escrow.
+	h.run(`
+table seats=2
+deal 3S 3C 5S 6S 7S 8S 9S 10S JS QS KS 2D 2H | 4C 4D 5C 5D 6C 6D 7C 7D 8H 9H 10H JH QH
+play 0 3S 3C
+pass 1
+play 0 2D 2H
+drain *
+play 1 4C 4D 5C 5D 6C 6D 7C 7D
+see * move_played
+see * chop {"chopper":1,"chopped":0,"value":1200,"amount":400}
+`)
+	select {
+	case paid := <-gold.chops:
+		if paid.Amount != 400 {
+			t.Errorf("paid %d, want 400", paid.Amount)
+		}
+	case <-time.After(messageWait):
+		t.Fatal("the chop was not paid")
+	}
+}
+
+// choppedGame is a two-seat game in which seat 1 chops seat 0's 2S with
+// four aces, worth 2 antes, and seat 0 then goes out.
+const choppedGame = `
+table seats=2
+deal 3S 4S 5S 6S 7S 8S 9S 10S JS QS KS 2S 2C | AS AC AD AH 3C 3D 5C 6D 7H 8C 9D 10H JC
+play 0 3S 4S 5S 6S 7S 8S 9S 10S JS QS KS
+pass 1
+play 0 2S
+play 1 AS AC AD AH
+pass 0
+play 1 3C
+play 0 2C
+`
+
+func TestSettlementWaitsForChops(t *testing.T) {
+	h := newHarness(t, nil)
+	gold := newMemGold(0)
+	gold.chopFailures = settlementAttempts
+	h.engine.gold = gold
+	h.run(choppedGame)
+
+	// The settlement waits while the chop is retried, then books it.
+	for n := 1; ; n++ {
+		select {
+		case <-gold.chopAttempts:
+		case <-time.After(messageWait):
+			t.Fatalf("no chop attempt %d", n)
+		}
+		if n == settlementAttempts {
+			break
+		}
+		select {
+		case got := <-gold.attempts:
+			t.Fatalf("settlement attempt %d came before chop attempt %d", got, n)
+		default:
+		}
+		h.advance(time.Duration(n) * time.Second)
+	}
+	gold.waitAttempt(t, 1)
+	gold.mu.Lock()
+	chops := gold.settled[0].ChopPayments()
+	gold.mu.Unlock()
+	if len(chops) != 1 || chops[0].Amount != 200 || chops[0].FromUserID != 100 {
+		t.Errorf("settlement chops = %+v, want seat 0 paying 200", chops)
+	}
+}
+
+func TestSettlementFailureSaysWhatWasPaid(t *testing.T) {
+	h := newHarness(t, nil)
+	gold := newMemGold(settlementAttempts)
+	h.engine.gold = gold
+	h.run(choppedGame)
+
+	<-gold.chops
+	for n := 1; n < settlementAttempts; n++ {
+		gold.waitAttempt(t, n)
+		h.advance(time.Duration(n) * time.Second)
+	}
+	gold.waitAttempt(t, settlementAttempts)
+
+	msg, ok := settlementMessage(h, h.seats[0])
+	if !ok || msg.Type != ws.MsgError || !strings.Contains(string(msg.Payload), "only 1 chop was paid") {
+		t.Fatalf("got %s, want the failure to mention the paid chop", describe([]ws.Message{msg}))
+	}
+}
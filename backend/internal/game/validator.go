@@ -126,13 +126,23 @@ func CanBeat(table *models.TablePlay, play []models.Card, playCombo models.Combi
 		}
 	}
 
-	// "Chop" pair of 2s with double sequence of 4+ pairs
+	// "Chop" pair of 2s with four-of-a-kind or double sequence of 4+ pairs
 	if tableCombo == models.ComboPair && tableCards[0].Rank == models.Two {
+		if playCombo == models.ComboFourOfAKind {
+			return true
+		}
 		if playCombo == models.ComboDoubleSequence && len(play) >= 8 {
 			return true
 		}
 	}
 
+	// A stronger kind of bomb beats a weaker one regardless of rank
+	tableBomb := BombRank(tableCombo, len(tableCards))
+	playBomb := BombRank(playCombo, len(play))
+	if tableBomb > 0 && playBomb > 0 && playBomb != tableBomb {
+		return playBomb > tableBomb
+	}
+
 	// Normal beating: same combo type, same card count, higher value
 	if playCombo != tableCombo || len(play) != len(tableCards) {
 		return false
@@ -150,6 +160,21 @@ func CanBeat(table *models.TablePlay, play []models.Card, playCombo models.Combi
 	return playHigh.Value() > tableHigh.Value()
 }
 
+// BombRank orders the combinations that can chop 2s: three consecutive
+// pairs rank 1, four-of-a-kind 2, and n consecutive pairs (n >= 4) rank n-1.
+// Anything else ranks 0.
+func BombRank(combo models.CombinationType, n int) int {
+	switch {
+	case combo == models.ComboFourOfAKind:
+		return 2
+	case combo == models.ComboDoubleSequence && n == 6:
+		return 1
+	case combo == models.ComboDoubleSequence && n >= 8:
+		return n/2 - 1
+	}
+	return 0
+}
+
 // PlayerOwnsCards checks that all cards in 'played' exist in 'hand'
 func PlayerOwnsCards(hand, played []models.Card) bool {
 	handMap := make(map[int]int)
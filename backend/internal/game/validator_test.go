@@ -0,0 +1,299 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+// The tests here check ClassifyCombination and CanBeat against a reference
+// that builds every legal play from the rules directly instead of
+// recognising them.
+
+// refCombo is a legal play and what kind it is.
+type refCombo struct {
+	kind  models.CombinationType
+	cards []models.Card
+}
+
+// refCombos lists every legal Mien Nam play that can be made from hand:
+// each card on its own, every two, three or four cards of one rank, and
+// every run of three or more consecutive ranks below 2 taken one card or
+// one pair per rank.
+func refCombos(hand []models.Card) []refCombo {
+	var byRank [13][]models.Card
+	for _, c := range hand {
+		byRank[c.Rank] = append(byRank[c.Rank], c)
+	}
+
+	var out []refCombo
+	kinds := map[int]models.CombinationType{1: models.ComboSingle, 2: models.ComboPair, 3: models.ComboTriple, 4: models.ComboFourOfAKind}
+	for _, cards := range byRank {
+		for k := 1; k <= len(cards); k++ {
+			for _, set := range subsets(cards, k) {
+				out = append(out, refCombo{kinds[k], set})
+			}
+		}
+	}
+
+	runKinds := []struct {
+		per  int
+		kind models.CombinationType
+	}{{1, models.ComboSequence}, {2, models.ComboDoubleSequence}}
+	for _, rk := range runKinds {
+		per, kind := rk.per, rk.kind
+		for lo := models.Three; lo <= models.Ace; lo++ {
+			runs := [][]models.Card{nil}
+			for r := lo; r <= models.Ace; r++ {
+				var next [][]models.Card
+				for _, run := range runs {
+					for _, set := range subsets(byRank[r], per) {
+						next = append(next, append(append([]models.Card(nil), run...), set...))
+					}
+				}
+				if runs = next; len(runs) == 0 {
+					break
+				}
+				if int(r-lo) >= 2 {
+					for _, run := range runs {
+						out = append(out, refCombo{kind, run})
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+// subsets returns every k-card subset of cards.
+func subsets(cards []models.Card, k int) [][]models.Card {
+	if k == 0 {
+		return [][]models.Card{nil}
+	}
+	var out [][]models.Card
+	for i := 0; i+k <= len(cards); i++ {
+		for _, rest := range subsets(cards[i+1:], k-1) {
+			out = append(out, append([]models.Card{cards[i]}, rest...))
+		}
+	}
+	return out
+}
+
+// bombOrder lists the bombs from weakest to strongest: three consecutive
+// pairs, four of a kind, then four or more consecutive pairs by length. A
+// hand holds at most six pairs, but the reference deals 26 cards, so the
+// longer runs are listed too.
+var bombOrder = []struct {
+	kind  models.CombinationType
+	cards int
+}{
+	{models.ComboDoubleSequence, 6},
+	{models.ComboFourOfAKind, 4},
+	{models.ComboDoubleSequence, 8},
+	{models.ComboDoubleSequence, 10},
+	{models.ComboDoubleSequence, 12},
+	{models.ComboDoubleSequence, 14},
+	{models.ComboDoubleSequence, 16},
+	{models.ComboDoubleSequence, 18},
+	{models.ComboDoubleSequence, 20},
+	{models.ComboDoubleSequence, 22},
+	{models.ComboDoubleSequence, 24},
+}
+
+// refBomb returns c's place in bombOrder, counting from 1, or 0 when c is
+// not a bomb.
+func refBomb(c refCombo) int {
+	for i, b := range bombOrder {
+		if c.kind == b.kind && len(c.cards) == b.cards {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func refHigh(cards []models.Card) models.Card {
+	high := cards[0]
+	for _, c := range cards[1:] {
+		if c.Value() > high.Value() {
+			high = c
+		}
+	}
+	return high
+}
+
+// refBeats says whether play beats table under Mien Nam rules:
+//   - any bomb chops a single 2; four of a kind or four or more pairs chop
+//     a pair of 2s;
+//   - a stronger bomb beats a weaker one;
+//   - otherwise a play beats the same kind with as many cards by its
+//     highest card.
+func refBeats(table, play refCombo) bool {
+	twos := refHigh(table.cards).Rank == models.Two
+	switch {
+	case twos && table.kind == models.ComboSingle && refBomb(play) > 0:
+		return true
+	case twos && table.kind == models.ComboPair &&
+		(play.kind == models.ComboFourOfAKind || play.kind == models.ComboDoubleSequence && len(play.cards) >= 8):
+		return true
+	case refBomb(table) > 0 && refBomb(play) > refBomb(table):
+		return true
+	}
+	return play.kind == table.kind && len(play.cards) == len(table.cards) &&
+		refHigh(play.cards).Value() > refHigh(table.cards).Value()
+}
+
+func cardMask(cards []models.Card) uint64 {
+	var m uint64
+	for _, c := range cards {
+		m |= 1 << c.Value()
+	}
+	return m
+}
+
+// refClassify classifies cards by looking for a reference play that uses
+// all of them.
+func refClassify(cards []models.Card) (models.CombinationType, bool) {
+	want := cardMask(cards)
+	for _, c := range refCombos(cards) {
+		if len(c.cards) == len(cards) && cardMask(c.cards) == want {
+			return c.kind, true
+		}
+	}
+	return 0, false
+}
+
+func randomHand(rng *rand.Rand, n int) []models.Card {
+	deck := models.NewDeck()
+	rng.Shuffle(len(deck), func(i, j int) { deck[i], deck[j] = deck[j], deck[i] })
+	return deck[:n]
+}
+
+// decodeCards turns fuzz input into at most 13 distinct cards.
+func decodeCards(data []byte) []models.Card {
+	deck := models.NewDeck()
+	var cards []models.Card
+	var seen uint64
+	for _, b := range data {
+		v := int(b) % len(deck)
+		if seen&(1<<v) != 0 {
+			continue
+		}
+		seen |= 1 << v
+		if cards = append(cards, deck[v]); len(cards) == 13 {
+			break
+		}
+	}
+	return cards
+}
+
+func cardString(cards []models.Card) string {
+	return fmt.Sprint(cards)
+}
+
+// Every subset of a hand is classified the way the reference builds it.
+func TestClassifyMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 50; round++ {
+		hand := randomHand(rng, 13)
+		want := make(map[uint64]models.CombinationType)
+		for _, c := range refCombos(hand) {
+			want[cardMask(c.cards)] = c.kind
+		}
+
+		for set := 1; set < 1<<len(hand); set++ {
+			var cards []models.Card
+			for i, c := range hand {
+				if set&(1<<i) != 0 {
+					cards = append(cards, c)
+				}
+			}
+			wantKind, wantOK := want[cardMask(cards)]
+			got, ok := ClassifyCombination(append([]models.Card(nil), cards...))
+			if ok != wantOK || (ok && got != wantKind) {
+				t.Fatalf("ClassifyCombination(%s) = %v, %v; want %v, %v", cardString(cards), got, ok, wantKind, wantOK)
+			}
+		}
+	}
+}
+
+func TestCanBeatMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for round := 0; round < 200; round++ {
+		combos := refCombos(randomHand(rng, 26))
+		for i := 0; i < 500; i++ {
+			table, play := combos[rng.Intn(len(combos))], combos[rng.Intn(len(combos))]
+			tp := &models.TablePlay{Cards: table.cards, ComboType: table.kind}
+			got := CanBeat(tp, append([]models.Card(nil), play.cards...), play.kind)
+			if want := refBeats(table, play); got != want {
+				t.Fatalf("CanBeat(%s on %s) = %v, want %v", cardString(play.cards), cardString(table.cards), got, want)
+			}
+		}
+	}
+}
+
+func TestBombRank(t *testing.T) {
+	tests := []struct {
+		combo models.CombinationType
+		n     int
+		want  int
+	}{
+		{models.ComboSingle, 1, 0},
+		{models.ComboPair, 2, 0},
+		{models.ComboTriple, 3, 0},
+		{models.ComboSequence, 6, 0},
+		{models.ComboDoubleSequence, 6, 1},
+		{models.ComboFourOfAKind, 4, 2},
+		{models.ComboDoubleSequence, 8, 3},
+		{models.ComboDoubleSequence, 10, 4},
+		{models.ComboDoubleSequence, 12, 5},
+	}
+	for _, tt := range tests {
+		if got := BombRank(tt.combo, tt.n); got != tt.want {
+			t.Errorf("BombRank(%v, %d) = %d, want %d", tt.combo, tt.n, got, tt.want)
+		}
+	}
+}
+
+func FuzzClassifyCombination(f *testing.F) {
+	f.Add([]byte{0})
+	f.Add([]byte{0, 1})
+	f.Add([]byte{0, 4, 8})
+	f.Add([]byte{0, 1, 4, 5, 8, 9})
+	f.Add([]byte{36, 40, 44, 48})
+	f.Add([]byte{40, 41, 44, 45, 48, 49})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cards := decodeCards(data)
+		wantKind, wantOK := refClassify(cards)
+		got, ok := ClassifyCombination(append([]models.Card(nil), cards...))
+		if ok != wantOK || (ok && got != wantKind) {
+			t.Fatalf("ClassifyCombination(%s) = %v, %v; want %v, %v", cardString(cards), got, ok, wantKind, wantOK)
+		}
+	})
+}
+
+func FuzzCanBeat(f *testing.F) {
+	f.Add([]byte{48}, []byte{0, 1, 2, 3})
+	f.Add([]byte{48, 49}, []byte{0, 1, 4, 5, 8, 9})
+	f.Add([]byte{0, 1, 4, 5, 8, 9}, []byte{12, 13, 16, 17, 20, 21})
+	f.Add([]byte{0, 4, 8}, []byte{4, 8, 12, 16})
+	f.Fuzz(func(t *testing.T, a, b []byte) {
+		tableCards, playCards := decodeCards(a), decodeCards(b)
+		tableKind, ok := refClassify(tableCards)
+		if !ok {
+			return
+		}
+		playKind, ok := refClassify(playCards)
+		if !ok {
+			return
+		}
+		table := refCombo{tableKind, tableCards}
+		play := refCombo{playKind, playCards}
+		tp := &models.TablePlay{Cards: tableCards, ComboType: tableKind}
+		got := CanBeat(tp, append([]models.Card(nil), playCards...), playKind)
+		if want := refBeats(table, play); got != want {
+			t.Fatalf("CanBeat(%s on %s) = %v, want %v", cardString(playCards), cardString(tableCards), got, want)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package game
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+// VerifyGame plays a recorded game again under its rules and lists every
+// way the record disagrees with the result: an illegal move, a game that
+// should or should not have ended, or a settlement that differs in winner,
+// reason, pot, fee, finishing order, chops or any seat's gold or chop gold.
+// An empty list means the record holds up.
+func VerifyGame(rep *models.GameReplay) []string {
+	want, err := SimulateGame(rep)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	got := rep.Settlement
+	switch {
+	case rep.Status == models.GameInProgress:
+		return nil
+	case want == nil && got != nil:
+		return []string{"recorded a settlement, but the moves do not finish the game"}
+	case want == nil:
+		return nil
+	case rep.Status == models.GameAborted:
+		return []string{fmt.Sprintf("recorded as aborted, but the moves finish the game: seat %d wins (%s)", want.Winner, want.Reason)}
+	case got == nil:
+		return []string{"recorded as finished without a settlement"}
+	}
+
+	var diffs []string
+	diff := func(field string, recorded, simulated interface{}) {
+		if !reflect.DeepEqual(recorded, simulated) {
+			diffs = append(diffs, fmt.Sprintf("%s: recorded %v, rules give %v", field, recorded, simulated))
+		}
+	}
+	diff("winner", got.Winner, want.Winner)
+	diff("reason", got.Reason, want.Reason)
+	diff("instant win", got.InstantWin, want.InstantWin)
+	diff("pot", got.TotalPot, want.TotalPot)
+	diff("server fee", got.ServerFee, want.ServerFee)
+	diff("finish order", got.FinishOrder, want.FinishOrder)
+	diff("sâm declarer", seatOrNone(got.SamDeclarer), seatOrNone(want.SamDeclarer))
+	for i := 0; i < len(got.Chops) || i < len(want.Chops); i++ {
+		diff(fmt.Sprintf("chop %d", i+1), chopAt(got, i), chopAt(want, i))
+	}
+	for seat := 0; seat < rep.Game.Seats; seat++ {
+		diff(fmt.Sprintf("seat %d gold", seat), goldDelta(got, seat), goldDelta(want, seat))
+		diff(fmt.Sprintf("seat %d chop gold", seat), chopDelta(got, seat), chopDelta(want, seat))
+	}
+	return diffs
+}
+
+// VerifyDeal deals a recorded game again from its revealed seeds and lists
+// every way that disagrees with the record: a seed that does not match its
+// commitment, a seat dealt a different hand, or a settlement that revealed
+// other seeds. A game recorded without seeds has nothing to check.
+func VerifyDeal(rep *models.GameReplay) []string {
+	g := &rep.Game
+	if g.Deck == nil {
+		return nil
+	}
+	if s := rep.Settlement; s != nil && s.Deck != nil && !reflect.DeepEqual(s.Deck, g.Deck) {
+		return []string{"the settlement revealed other seeds than were recorded at the deal"}
+	}
+	hands, err := g.Deck.Deal(RulesFor(&models.Room{Rules: g.Rules}).HandSize())
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if len(hands) != g.Seats {
+		return []string{fmt.Sprintf("the seeds deal %d seats, the game had %d", len(hands), g.Seats)}
+	}
+
+	var diffs []string
+	for _, p := range g.Players {
+		if p.Seat < 0 || p.Seat >= len(hands) {
+			continue
+		}
+		if !reflect.DeepEqual(p.Hand, hands[p.Seat]) {
+			diffs = append(diffs, fmt.Sprintf("seat %d hand: recorded %s, seeds deal %s",
+				p.Seat, models.FormatCards(p.Hand), models.FormatCards(hands[p.Seat])))
+		}
+	}
+	return diffs
+}
+
+func seatOrNone(seat *int) interface{} {
+	if seat == nil {
+		return "none"
+	}
+	return *seat
+}
+
+// goldDelta is what seat won or lost in s, or 0 if it has no result.
+func goldDelta(s *models.Settlement, seat int) int {
+	if r := resultFor(s, seat); r != nil {
+		return r.GoldDelta
+	}
+	return 0
+}
+
+// chopDelta is what seat won or lost in chops in s.
+func chopDelta(s *models.Settlement, seat int) int {
+	if r := resultFor(s, seat); r != nil {
+		return r.ChopDelta
+	}
+	return 0
+}
+
+func resultFor(s *models.Settlement, seat int) *models.SettlementResult {
+	for _, r := range s.Results {
+		if r != nil && r.Seat == seat {
+			return r
+		}
+	}
+	return nil
+}
+
+// chopAt describes chop i of s, or "none".
+func chopAt(s *models.Settlement, i int) string {
+	if i >= len(s.Chops) {
+		return "none"
+	}
+	c := s.Chops[i]
+	return fmt.Sprintf("seat %d chopped seat %d's %s with %s for %d of %d",
+		c.Chopper, c.Chopped, models.FormatCards(c.ChoppedCards), models.FormatCards(c.Cards), c.Amount, c.Value)
+}
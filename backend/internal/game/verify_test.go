@@ -0,0 +1,209 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/ws"
+)
+
+func TestVerifyGame(t *testing.T) {
+	_, rep, _ := playRecorded(t)
+
+	if diffs := VerifyGame(rep); len(diffs) != 0 {
+		t.Fatalf("recorded game does not verify: %v", diffs)
+	}
+	// A game exported to notation and read back still verifies.
+	parsed, err := models.ParseGame(models.FormatGame(rep))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs := VerifyGame(parsed); len(diffs) != 0 {
+		t.Fatalf("game read from notation does not verify: %v", diffs)
+	}
+
+	for name, tc := range map[string]struct {
+		tamper func(*models.GameReplay)
+		want   string
+	}{
+		"gold": {
+			func(r *models.GameReplay) { r.Settlement.Results[1].GoldDelta = -50 },
+			"seat 1 gold: recorded -50, rules give -300",
+		},
+		"winner": {
+			func(r *models.GameReplay) { r.Settlement.Winner = 1 },
+			"winner: recorded 1, rules give 0",
+		},
+		"aborted": {
+			func(r *models.GameReplay) { r.Status, r.Settlement = models.GameAborted, nil },
+			"recorded as aborted",
+		},
+		"unfinished": {
+			func(r *models.GameReplay) { r.Moves = r.Moves[:4] },
+			"do not finish the game",
+		},
+		"illegal move": {
+			func(r *models.GameReplay) { r.Moves[2].Cards = parseCards(t, "4C") },
+			"move 3: you don't have those cards",
+		},
+	} {
+		r, err := models.ParseGame(models.FormatGame(rep))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.tamper(r)
+		diffs := VerifyGame(r)
+		if len(diffs) != 1 || !strings.Contains(diffs[0], tc.want) {
+			t.Errorf("%s: got %q, want %q", name, diffs, tc.want)
+		}
+	}
+}
+
+func TestVerifyGameChecksChops(t *testing.T) {
+	store := newMemHistory()
+	h := newHarness(t, store)
+	h.run(choppedGame)
+	rep, err := store.GetReplay(context.Background(), store.waitEnded(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Settlement.Chops) != 1 {
+		t.Fatalf("recorded chops = %+v", rep.Settlement.Chops)
+	}
+	if diffs := VerifyGame(rep); len(diffs) != 0 {
+		t.Fatalf("recorded game does not verify: %v", diffs)
+	}
+
+	for name, tc := range map[string]struct {
+		tamper func(*models.Settlement)
+		want   string
+	}{
+		"chop amount": {
+			func(s *models.Settlement) { s.Chops[0].Amount = 100 },
+			"chop 1: recorded seat 1 chopped seat 0's 2S with AS AC AD AH for 100 of 200",
+		},
+		"missing chop": {
+			func(s *models.Settlement) { s.Chops = nil },
+			"chop 1: recorded none",
+		},
+		"chop gold": {
+			func(s *models.Settlement) { s.Results[1].ChopDelta = 0 },
+			"seat 1 chop gold: recorded 0, rules give 200",
+		},
+	} {
+		r, err := models.ParseGame(models.FormatGame(rep))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.tamper(r.Settlement)
+		diffs := VerifyGame(r)
+		if len(diffs) != 1 || !strings.Contains(diffs[0], tc.want) {
+			t.Errorf("%s: got %q, want %q", name, diffs, tc.want)
+		}
+	}
+}
+
+func TestDealFollowsCommittedSeed(t *testing.T) {
+	store := newMemHistory()
+	h := newHarness(t, store)
+	h.run("table seats=2")
+	h.join(h.seats[0])
+	h.join(h.seats[1])
+
+	var committed string
+	for _, msg := range h.pending(h.seats[0]) {
+		var info models.RoomInfo
+		if msg.Type == ws.MsgRoomUpdate && json.Unmarshal(msg.Payload, &info) == nil && info.SeedHash != "" {
+			committed = info.SeedHash
+		}
+	}
+	if committed == "" {
+		t.Fatalf("no seed hash before the deal: %s", describe(h.pending(h.seats[0])))
+	}
+
+	h.send(h.seats[0], ws.MsgReady, ws.ReadyPayload{ClientSeed: strings.Repeat("x", models.MaxClientSeedLen+1)})
+	if msgs := h.pending(h.seats[0]); msgs[len(msgs)-1].Type != ws.MsgError {
+		t.Fatalf("overlong client seed accepted: %s", describe(msgs))
+	}
+	h.send(h.seats[0], ws.MsgReady, ws.ReadyPayload{ClientSeed: "lucky"})
+	h.send(h.seats[1], ws.MsgReady, nil)
+
+	var dealt [2]GameStatePayload
+	for seat, c := range h.seats[:2] {
+		msg, ok := h.next(c)
+		for ok && msg.Type != ws.MsgCardDealt {
+			msg, ok = h.next(c)
+		}
+		if !ok {
+			t.Fatalf("seat %d was not dealt in", seat)
+		}
+		json.Unmarshal(msg.Payload, &dealt[seat])
+		if dealt[seat].SeedHash != committed {
+			t.Errorf("seat %d dealt under seed hash %q, committed %q", seat, dealt[seat].SeedHash, committed)
+		}
+	}
+
+	h.send(h.seats[0], ws.MsgLeaveRoom, nil)
+	rep, _ := store.GetReplay(context.Background(), store.waitEnded(t))
+
+	// The aborted game puts the room back in the lobby already committed to
+	// the next deal.
+	var next string
+	for _, msg := range h.pending(h.seats[1]) {
+		var info models.RoomInfo
+		if msg.Type == ws.MsgRoomUpdate && json.Unmarshal(msg.Payload, &info) == nil && info.Phase == models.PhaseLobby {
+			next = info.SeedHash
+		}
+	}
+	if next == "" || next == committed {
+		t.Errorf("seed hash after the abort = %q, the aborted deal's was %q", next, committed)
+	}
+
+	deck := rep.Game.Deck
+	if deck == nil || deck.SeedHash != committed || !reflect.DeepEqual(deck.ClientSeeds, []string{"lucky", ""}) {
+		t.Fatalf("recorded deck = %+v", deck)
+	}
+	hands, err := deck.Deal(13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seat := range dealt {
+		if !reflect.DeepEqual(hands[seat], dealt[seat].Hand) {
+			t.Errorf("seeds deal seat %d %v, it was dealt %v", seat, hands[seat], dealt[seat].Hand)
+		}
+	}
+	if diffs := VerifyDeal(rep); len(diffs) != 0 {
+		t.Errorf("deal does not verify: %v", diffs)
+	}
+
+	rep.Game.Deck = &models.DeckProof{SeedHash: deck.SeedHash, ServerSeed: deck.ServerSeed, ClientSeeds: []string{"lucky", "me too"}}
+	if diffs := VerifyDeal(rep); len(diffs) == 0 || !strings.Contains(diffs[0], "seat 0 hand") {
+		t.Errorf("other client seeds verified: %v", diffs)
+	}
+	rep.Game.Deck = &models.DeckProof{SeedHash: deck.SeedHash, ServerSeed: strings.Repeat("00", models.SeedSize), ClientSeeds: deck.ClientSeeds}
+	if diffs := VerifyDeal(rep); len(diffs) != 1 || !strings.Contains(diffs[0], "not the committed") {
+		t.Errorf("other server seed verified: %v", diffs)
+	}
+}
+
+func TestReadyRefusesClientSeedBeforeCommitment(t *testing.T) {
+	h := newHarness(t, nil)
+	h.run("table seats=2")
+	h.join(h.seats[0])
+	h.hub.DoWait(harnessRoomID, func(room *models.Room) { room.DeckSeed = nil })
+
+	h.send(h.seats[0], ws.MsgReady, ws.ReadyPayload{ClientSeed: "early"})
+	msgs := h.pending(h.seats[0])
+	if msgs[len(msgs)-1].Type != ws.MsgError {
+		t.Fatalf("client seed accepted with no seed hash published: %s", describe(msgs))
+	}
+	var ready bool
+	h.hub.DoWait(harnessRoomID, func(room *models.Room) { ready = room.Players[0].IsReady })
+	if ready {
+		t.Error("player was readied")
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/game-playzui/tienlen-server/internal/auth"
+	"github.com/game-playzui/tienlen-server/internal/game"
+	"github.com/game-playzui/tienlen-server/internal/models"
+	"github.com/game-playzui/tienlen-server/internal/repository"
+)
+
+type GameHandler struct {
+	historyRepo *repository.HistoryRepo
+	admins      auth.Admins
+}
+
+func NewGameHandler(historyRepo *repository.HistoryRepo, admins auth.Admins) *GameHandler {
+	return &GameHandler{historyRepo: historyRepo, admins: admins}
+}
+
+// seesHands reports whether the caller may see every hand of rep: they
+// played in it, or are an admin.
+func (h *GameHandler) seesHands(r *http.Request, rep *models.GameReplay) bool {
+	claims := auth.GetClaims(r)
+	return claims != nil && (rep.HasPlayer(claims.UserID) || h.admins.Has(claims.UserID))
+}
+
+// DealCheck is the result of dealing a recorded game again from its seeds.
+type DealCheck struct {
+	GameID   string            `json:"game_id"`
+	Deck     *models.DeckProof `json:"deck"`
+	Hands    [][]models.Card   `json:"hands"`
+	Verified bool              `json:"verified"`
+	Problems []string          `json:"problems,omitempty"`
+}
+
+// Replay returns everything recorded about a game: every dealt hand, the
+// moves in order and the settlement. Games still being played are not
+// shown, since that would reveal the hands, and only the game's players and
+// admins see the hands and seeds at all. ?format=text returns the game in
+// the text notation instead of JSON.
+func (h *GameHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	rep, err := h.historyRepo.GetReplay(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load game"})
+		return
+	}
+	if rep == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "game not found"})
+		return
+	}
+	if rep.Status == models.GameInProgress {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "game is still in progress"})
+		return
+	}
+	if !h.seesHands(r, rep) {
+		rep = rep.Public()
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(models.FormatGame(rep)))
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// Deal deals a finished game again from the seeds revealed in its
+// settlement and reports whether every seat got the hand it was recorded
+// with. Hands lists the recorded hands by seat, so only the game's players
+// and admins may ask.
+func (h *GameHandler) Deal(w http.ResponseWriter, r *http.Request) {
+	rep, err := h.historyRepo.GetReplay(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load game"})
+		return
+	}
+	if rep == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "game not found"})
+		return
+	}
+	if rep.Status == models.GameInProgress {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "game is still in progress"})
+		return
+	}
+	if !h.seesHands(r, rep) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "only the game's players can deal it again"})
+		return
+	}
+	if rep.Game.Deck == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "game was dealt without seeds"})
+		return
+	}
+
+	check := DealCheck{
+		GameID: rep.Game.GameID,
+		Deck:   rep.Game.Deck,
+		Hands:  make([][]models.Card, rep.Game.Seats),
+	}
+	for _, p := range rep.Game.Players {
+		if p.Seat >= 0 && p.Seat < len(check.Hands) {
+			check.Hands[p.Seat] = p.Hand
+		}
+	}
+	check.Problems = game.VerifyDeal(rep)
+	check.Verified = len(check.Problems) == 0
+	writeJSON(w, http.StatusOK, check)
+}
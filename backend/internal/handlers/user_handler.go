@@ -2,17 +2,27 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/game-playzui/tienlen-server/internal/auth"
 	"github.com/game-playzui/tienlen-server/internal/repository"
 )
 
+const (
+	defaultTransactionsLimit = 20
+	maxTransactionsLimit     = 100
+	defaultGamesLimit        = 20
+	maxGamesLimit            = 50
+)
+
 type UserHandler struct {
-	userRepo *repository.UserRepo
+	userRepo    *repository.UserRepo
+	goldRepo    *repository.GoldRepo
+	historyRepo *repository.HistoryRepo
 }
 
-func NewUserHandler(userRepo *repository.UserRepo) *UserHandler {
-	return &UserHandler{userRepo: userRepo}
+func NewUserHandler(userRepo *repository.UserRepo, goldRepo *repository.GoldRepo, historyRepo *repository.HistoryRepo) *UserHandler {
+	return &UserHandler{userRepo: userRepo, goldRepo: goldRepo, historyRepo: historyRepo}
 }
 
 func (h *UserHandler) Profile(w http.ResponseWriter, r *http.Request) {
@@ -30,3 +40,83 @@ func (h *UserHandler) Profile(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, user.ToProfile())
 }
+
+// Transactions lists the caller's gold ledger, newest first.
+// Paginate with ?limit= (default 20, max 100) and ?offset=.
+func (h *UserHandler) Transactions(w http.ResponseWriter, r *http.Request) {
+	claims := auth.GetClaims(r)
+	if claims == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	limit, offset, ok := parsePagination(r, defaultTransactionsLimit, maxTransactionsLimit)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid limit or offset"})
+		return
+	}
+
+	txs, total, err := h.goldRepo.ListTransactions(r.Context(), claims.UserID, limit, offset)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load transactions"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"transactions": txs,
+		"total":        total,
+		"limit":        limit,
+		"offset":       offset,
+	})
+}
+
+// Games lists the games the caller played, newest first, with every
+// seat's result. Paginate with ?limit= (default 20, max 50) and ?offset=.
+func (h *UserHandler) Games(w http.ResponseWriter, r *http.Request) {
+	claims := auth.GetClaims(r)
+	if claims == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	limit, offset, ok := parsePagination(r, defaultGamesLimit, maxGamesLimit)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid limit or offset"})
+		return
+	}
+
+	games, total, err := h.historyRepo.ListUserGames(r.Context(), claims.UserID, limit, offset)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load games"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"games":  games,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func parsePagination(r *http.Request, defaultLimit, maxLimit int) (limit, offset int, ok bool) {
+	limit = defaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return limit, offset, true
+}
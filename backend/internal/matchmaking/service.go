@@ -54,7 +54,7 @@ func (s *Service) Start() {
 func (s *Service) RequestMatch(client *ws.Client, anteLevel int) {
 	validAntes := map[int]bool{100: true, 500: true, 1000: true}
 	if !validAntes[anteLevel] {
-		client.Send <- ws.NewErrorMessage("invalid ante level, must be 100, 500, or 1000")
+		client.Deliver(ws.NewErrorMessage("invalid ante level, must be 100, 500, or 1000"))
 		return
 	}
 	s.queue <- MatchRequest{Client: client, AnteLevel: anteLevel}
@@ -76,64 +76,79 @@ func (s *Service) processWaitLists() {
 		}
 
 		// Try to find a room with available seats for this ante level
-		room := s.findAvailableRoom(ante)
-		if room == nil {
+		roomID := s.findAvailableRoom(ante)
+		if roomID == 0 {
 			continue
 		}
 
 		// Place waiting players into the room
 		remaining := make([]MatchRequest, 0)
-		for _, w := range waiters {
+		for i, w := range waiters {
 			if w.Client.GetRoom() > 0 {
 				continue
 			}
 
-			room.Lock()
-			seat := room.FindEmptySeat()
+			var name string
+			claimed := true
+			seat := -1
+			s.hub.DoWait(roomID, func(room *models.Room) {
+				// The player may have joined a room themselves since the
+				// check above.
+				if claimed = w.Client.CompareAndSetRoom(0, room.ID); !claimed {
+					return
+				}
+				if room.Phase == models.PhaseLobby {
+					seat = room.FindEmptySeat()
+				}
+				if seat < 0 {
+					w.Client.CompareAndSetRoom(room.ID, 0)
+					return
+				}
+				room.Players[seat] = &models.Player{
+					UserID:    w.Client.UserID,
+					Username:  w.Client.Username,
+					SeatIndex: seat,
+				}
+				name = room.Name
+			})
+			if !claimed {
+				continue
+			}
 			if seat < 0 {
-				room.Unlock()
 				remaining = append(remaining, w)
-				room = s.findAvailableRoom(ante)
-				if room == nil {
-					remaining = append(remaining, waiters[len(waiters)-len(remaining):]...)
+				roomID = s.findAvailableRoom(ante)
+				if roomID == 0 {
+					remaining = append(remaining, waiters[i+1:]...)
 					break
 				}
 				continue
 			}
 
-			room.Players[seat] = &models.Player{
-				UserID:    w.Client.UserID,
-				Username:  w.Client.Username,
-				SeatIndex: seat,
-			}
-			w.Client.SetRoom(room.ID)
-			room.Unlock()
-
 			data, _ := ws.NewMessage(ws.MsgMatchFound, map[string]interface{}{
-				"room_id":   room.ID,
-				"room_name": room.Name,
+				"room_id":   roomID,
+				"room_name": name,
 				"seat":      seat,
 			})
-			w.Client.Send <- data
+			w.Client.Deliver(data)
 		}
 
 		s.waitLists[ante] = remaining
 	}
 }
 
-func (s *Service) findAvailableRoom(ante int) *models.Room {
-	for _, room := range s.hub.Rooms {
-		room.RLock()
-		if room.AnteAmount == ante && room.Phase == models.PhaseLobby && room.PlayerCount() < 4 {
-			room.RUnlock()
-			return room
+// findAvailableRoom returns a lobby room at this ante with a free seat, or 0.
+// The seat may be gone by the time the caller takes it.
+func (s *Service) findAvailableRoom(ante int) int {
+	for id := range s.hub.Rooms {
+		info, _ := s.hub.RoomInfo(id)
+		if info.AnteAmount == ante && info.Phase == models.PhaseLobby && info.PlayerCount < info.Seats {
+			return id
 		}
-		room.RUnlock()
 	}
-	return nil
+	return 0
 }
 
-func (s *Service) FindAvailableRoom(ante int) *models.Room {
+func (s *Service) FindAvailableRoom(ante int) int {
 	return s.findAvailableRoom(ante)
 }
 
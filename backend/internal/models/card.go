@@ -1,10 +1,8 @@
 package models
 
 import (
-	"crypto/rand"
 	"encoding/json"
 	"fmt"
-	"math/big"
 	"sort"
 )
 
@@ -146,23 +144,24 @@ func NewDeck() []Card {
 	return deck
 }
 
-func ShuffleDeck(deck []Card) {
+func ShuffleDeck(deck []Card, rng Rand) {
 	n := len(deck)
 	for i := n - 1; i > 0; i-- {
-		jBig, _ := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
-		j := jBig.Int64()
+		j := rng.Intn(i + 1)
 		deck[i], deck[j] = deck[j], deck[i]
 	}
 }
 
-func DealCards() [4][]Card {
+// DealCards deals size cards to each of n players from a deck shuffled with
+// rng. The rest of the deck is left undealt.
+func DealCards(rng Rand, n, size int) [][]Card {
 	deck := NewDeck()
-	ShuffleDeck(deck)
+	ShuffleDeck(deck, rng)
 
-	var hands [4][]Card
-	for i := 0; i < 4; i++ {
-		hands[i] = make([]Card, 13)
-		copy(hands[i], deck[i*13:(i+1)*13])
+	hands := make([][]Card, n)
+	for i := 0; i < n; i++ {
+		hands[i] = make([]Card, size)
+		copy(hands[i], deck[i*size:(i+1)*size])
 		SortCards(hands[i])
 	}
 	return hands
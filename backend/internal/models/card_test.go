@@ -0,0 +1,30 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDealCardsSeeded(t *testing.T) {
+	a := DealCards(NewSeededRand(42), 4, 13)
+	b := DealCards(NewSeededRand(42), 4, 13)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("same seed dealt different hands")
+	}
+	if c := DealCards(NewSeededRand(43), 4, 13); reflect.DeepEqual(a, c) {
+		t.Error("different seeds dealt the same hands")
+	}
+
+	seen := make(map[Card]bool)
+	for _, hand := range a {
+		if len(hand) != 13 {
+			t.Fatalf("hand has %d cards, want 13", len(hand))
+		}
+		for _, c := range hand {
+			if seen[c] {
+				t.Fatalf("%v dealt twice", c)
+			}
+			seen[c] = true
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// SeedSize is the length in bytes of a server deck seed.
+const SeedSize = 32
+
+// MaxClientSeedLen caps the entropy a player may add to a deal.
+const MaxClientSeedLen = 64
+
+// NewDeckSeed draws a fresh server seed for a deal from rng.
+func NewDeckSeed(rng Rand) []byte {
+	seed := make([]byte, SeedSize)
+	for i := range seed {
+		seed[i] = byte(rng.Intn(256))
+	}
+	return seed
+}
+
+// SeedHash is the commitment published for a server seed before the deal:
+// the hex SHA-256 of the seed bytes.
+func SeedHash(seed []byte) string {
+	sum := sha256.Sum256(seed)
+	return hex.EncodeToString(sum[:])
+}
+
+// DeckKey mixes the players' seeds, in seat order, into the server seed. It
+// is the HMAC-SHA256 keyed by the server seed of each client seed prefixed
+// by its length as a 4-byte big-endian integer, so no seat can shift its
+// entropy into another's.
+func DeckKey(serverSeed []byte, clientSeeds []string) []byte {
+	mac := hmac.New(sha256.New, serverSeed)
+	var n [4]byte
+	for _, s := range clientSeeds {
+		binary.BigEndian.PutUint32(n[:], uint32(len(s)))
+		mac.Write(n[:])
+		mac.Write([]byte(s))
+	}
+	return mac.Sum(nil)
+}
+
+// DeckRand is the Rand a deal is shuffled with. Its bytes are the
+// SHA-256 of the key followed by an 8-byte big-endian block counter,
+// counting from 0. Intn takes 4 bytes at a time as a big-endian number
+// and draws again while it falls in the uneven tail above the largest
+// multiple of n.
+type DeckRand struct {
+	key   []byte
+	block uint64
+	buf   []byte
+}
+
+func NewDeckRand(key []byte) *DeckRand {
+	return &DeckRand{key: key}
+}
+
+func (r *DeckRand) Intn(n int) int {
+	if n <= 0 {
+		panic("DeckRand.Intn: n must be positive")
+	}
+	limit := (1 << 32) / uint64(n) * uint64(n)
+	for {
+		if v := uint64(r.next()); v < limit {
+			return int(v % uint64(n))
+		}
+	}
+}
+
+func (r *DeckRand) next() uint32 {
+	if len(r.buf) < 4 {
+		h := sha256.New()
+		h.Write(r.key)
+		var c [8]byte
+		binary.BigEndian.PutUint64(c[:], r.block)
+		h.Write(c[:])
+		r.block++
+		r.buf = h.Sum(nil)
+	}
+	v := binary.BigEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v
+}
+
+// DeckProof is what it takes to deal a game again: the commitment shown
+// before the deal, the server seed revealed after it, and the seed each
+// seat added when it readied, empty if it added none.
+type DeckProof struct {
+	SeedHash    string   `json:"seed_hash"`
+	ServerSeed  string   `json:"server_seed"`
+	ClientSeeds []string `json:"client_seeds"`
+}
+
+// public is the proof without its seeds.
+func (d *DeckProof) public() *DeckProof {
+	if d == nil {
+		return nil
+	}
+	return &DeckProof{SeedHash: d.SeedHash}
+}
+
+// NewDeckProof records a deal from serverSeed and the seats' seeds.
+func NewDeckProof(serverSeed []byte, clientSeeds []string) *DeckProof {
+	return &DeckProof{
+		SeedHash:    SeedHash(serverSeed),
+		ServerSeed:  hex.EncodeToString(serverSeed),
+		ClientSeeds: clientSeeds,
+	}
+}
+
+// Deal checks the revealed seed against the commitment and deals the
+// hands again, size cards to each seat.
+func (p *DeckProof) Deal(size int) ([][]Card, error) {
+	seed, err := hex.DecodeString(p.ServerSeed)
+	if err != nil {
+		return nil, fmt.Errorf("server seed is not hex: %v", err)
+	}
+	if got := SeedHash(seed); got != p.SeedHash {
+		return nil, fmt.Errorf("server seed hashes to %s, not the committed %s", got, p.SeedHash)
+	}
+	seats := len(p.ClientSeeds)
+	if seats < MinSeats || seats > MaxSeats {
+		return nil, fmt.Errorf("%d client seeds for a %d to %d seat table", seats, MinSeats, MaxSeats)
+	}
+	if size <= 0 || seats*size > 52 {
+		return nil, fmt.Errorf("cannot deal %d cards to %d seats", size, seats)
+	}
+	return DealCards(NewDeckRand(DeckKey(seed, p.ClientSeeds)), seats, size), nil
+}
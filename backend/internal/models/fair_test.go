@@ -0,0 +1,72 @@
+package models
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDeckRand(t *testing.T) {
+	key := DeckKey([]byte("server"), []string{"a", ""})
+	a, b := NewDeckRand(key), NewDeckRand(key)
+	for i := 0; i < 1000; i++ {
+		n := 1 + i%52
+		x := a.Intn(n)
+		if x < 0 || x >= n {
+			t.Fatalf("Intn(%d) = %d", n, x)
+		}
+		if y := b.Intn(n); x != y {
+			t.Fatalf("draw %d: %d then %d from the same key", i, x, y)
+		}
+	}
+}
+
+func TestDeckKeySeparatesSeeds(t *testing.T) {
+	seed := []byte("server")
+	keys := [][]byte{
+		DeckKey(seed, []string{"ab", ""}),
+		DeckKey(seed, []string{"a", "b"}),
+		DeckKey(seed, []string{"", "ab"}),
+		DeckKey([]byte("other"), []string{"ab", ""}),
+	}
+	for i := range keys {
+		for j := i + 1; j < len(keys); j++ {
+			if bytes.Equal(keys[i], keys[j]) {
+				t.Errorf("keys %d and %d are the same", i, j)
+			}
+		}
+	}
+}
+
+func TestDeckProofDeal(t *testing.T) {
+	seed := NewDeckSeed(NewSeededRand(7))
+	if len(seed) != SeedSize {
+		t.Fatalf("seed is %d bytes", len(seed))
+	}
+	seeds := []string{"", "lucky", "", ""}
+	proof := NewDeckProof(seed, seeds)
+
+	hands, err := proof.Deal(13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := DealCards(NewDeckRand(DeckKey(seed, seeds)), 4, 13); !reflect.DeepEqual(hands, want) {
+		t.Errorf("Deal = %v, want %v", hands, want)
+	}
+
+	for name, tc := range map[string]struct {
+		proof DeckProof
+		size  int
+		want  string
+	}{
+		"wrong hash":     {DeckProof{SeedHash: SeedHash([]byte("x")), ServerSeed: proof.ServerSeed, ClientSeeds: seeds}, 13, "not the committed"},
+		"not hex":        {DeckProof{SeedHash: proof.SeedHash, ServerSeed: "zz", ClientSeeds: seeds}, 13, "not hex"},
+		"one seat":       {DeckProof{SeedHash: proof.SeedHash, ServerSeed: proof.ServerSeed, ClientSeeds: seeds[:1]}, 13, "client seeds"},
+		"too many cards": {*proof, 14, "cannot deal"},
+	} {
+		if _, err := tc.proof.Deal(tc.size); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: Deal error %v, want %q", name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package models
+
+import "time"
+
+// GameStatus is where a recorded game stands.
+type GameStatus string
+
+const (
+	GameInProgress GameStatus = "in_progress"
+	GameFinished   GameStatus = "finished"
+	GameAborted    GameStatus = "aborted" // a player left, or the settlement could not be booked
+)
+
+// GameRecord is a game as it was dealt.
+type GameRecord struct {
+	GameID     string    `json:"game_id"`
+	RoomID     int       `json:"room_id"`
+	Rules      RoomRules `json:"rules"`
+	Seats      int       `json:"seats"`
+	AnteAmount int       `json:"ante_amount"`
+	// FirstSeat leads the first round. OpeningCard, when set, must be in
+	// their first play.
+	FirstSeat   int                `json:"first_seat"`
+	OpeningCard *Card              `json:"opening_card,omitempty"`
+	Players     []GamePlayerRecord `json:"players"`
+	StartedAt   time.Time          `json:"started_at"`
+	// Deck holds the seeds the hands were dealt from.
+	Deck *DeckProof `json:"deck,omitempty"`
+}
+
+// GamePlayerRecord is one seat's player and dealt hand.
+type GamePlayerRecord struct {
+	Seat     int    `json:"seat"`
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	IsBot    bool   `json:"is_bot"`
+	Hand     []Card `json:"hand"`
+}
+
+// MoveAction is what a seat did on its turn.
+type MoveAction string
+
+const (
+	MovePlay       MoveAction = "play"
+	MovePass       MoveAction = "pass"
+	MoveTimeout    MoveAction = "timeout"     // the turn ran out and the seat was passed for
+	MoveDeclareSam MoveAction = "declare_sam" // Sâm Lốc: the seat báo sâm
+)
+
+// GameMove is one recorded action in a game. Seq numbers a game's moves
+// from 1 in the order they happened.
+type GameMove struct {
+	GameID string     `json:"-"`
+	Seq    int        `json:"seq"`
+	Seat   int        `json:"seat"`
+	Action MoveAction `json:"action"`
+	Cards  []Card     `json:"cards,omitempty"`
+	At     time.Time  `json:"at"`
+}
+
+// GameSummary is one game in a player's history, seen from their seat.
+type GameSummary struct {
+	GameID     string     `json:"game_id"`
+	RoomID     int        `json:"room_id"`
+	Rules      RoomRules  `json:"rules"`
+	AnteAmount int        `json:"ante_amount"`
+	Status     GameStatus `json:"status"`
+	Reason     string     `json:"reason,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+
+	Seat      int  `json:"seat"`
+	Won       bool `json:"won"`
+	Position  int  `json:"position,omitempty"`
+	GoldDelta int  `json:"gold_delta"`
+
+	Players []GameSummaryPlayer `json:"players"`
+}
+
+// GameSummaryPlayer is everyone's result in a GameSummary.
+type GameSummaryPlayer struct {
+	Seat      int    `json:"seat"`
+	Username  string `json:"username"`
+	IsBot     bool   `json:"is_bot"`
+	Position  int    `json:"position,omitempty"`
+	GoldDelta int    `json:"gold_delta"`
+}
+
+// GameReplay is everything recorded about a game.
+type GameReplay struct {
+	Game       GameRecord  `json:"game"`
+	Status     GameStatus  `json:"status"`
+	Moves      []GameMove  `json:"moves"`
+	Settlement *Settlement `json:"settlement,omitempty"`
+	EndedAt    *time.Time  `json:"ended_at,omitempty"`
+}
+
+// HasPlayer reports whether userID held a seat in the game.
+func (r *GameReplay) HasPlayer(userID int64) bool {
+	for _, p := range r.Game.Players {
+		if p.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Public returns a copy of the replay without what only the game's players
+// may see: the dealt hands, and the seeds they could be dealt again from.
+// The seed hash stays.
+func (r *GameReplay) Public() *GameReplay {
+	pub := *r
+	pub.Game.Players = make([]GamePlayerRecord, len(r.Game.Players))
+	for i, p := range r.Game.Players {
+		p.Hand = nil
+		pub.Game.Players[i] = p
+	}
+	pub.Game.Deck = r.Game.Deck.public()
+	if r.Settlement != nil {
+		s := *r.Settlement
+		s.Deck = s.Deck.public()
+		pub.Settlement = &s
+	}
+	return &pub
+}
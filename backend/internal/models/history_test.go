@@ -0,0 +1,35 @@
+package models
+
+import "testing"
+
+func TestGameReplayPublic(t *testing.T) {
+	deck := &DeckProof{SeedHash: "hash", ServerSeed: "seed", ClientSeeds: []string{"a", ""}}
+	rep := &GameReplay{
+		Game: GameRecord{
+			Players: []GamePlayerRecord{
+				{Seat: 0, UserID: 7, Hand: mustCards(t, "3S 4S")},
+				{Seat: 1, UserID: 8, Hand: mustCards(t, "5S 6S")},
+			},
+			Deck: deck,
+		},
+		Settlement: &Settlement{Winner: 0, Deck: deck},
+	}
+
+	pub := rep.Public()
+	for _, p := range pub.Game.Players {
+		if p.Hand != nil {
+			t.Errorf("seat %d's hand is shown: %v", p.Seat, p.Hand)
+		}
+	}
+	for _, d := range []*DeckProof{pub.Game.Deck, pub.Settlement.Deck} {
+		if d.SeedHash != "hash" || d.ServerSeed != "" || d.ClientSeeds != nil {
+			t.Errorf("deck = %+v, want only the seed hash", d)
+		}
+	}
+	if len(rep.Game.Players[1].Hand) != 2 || rep.Settlement.Deck.ServerSeed != "seed" {
+		t.Error("Public changed the replay it was made from")
+	}
+	if !rep.HasPlayer(8) || rep.HasPlayer(9) {
+		t.Error("HasPlayer does not match the seats")
+	}
+}
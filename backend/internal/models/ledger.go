@@ -0,0 +1,68 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// HouseUserID is the ledger account that takes the other side of server
+// fees, bot seats, rewards and admin adjustments.
+const HouseUserID int64 = 0
+
+// StartingGold is credited to every new account as a signup bonus.
+const StartingGold int64 = 10000
+
+type TransferKind string
+
+const (
+	TransferOpeningBalance  TransferKind = "opening_balance"
+	TransferSignupBonus     TransferKind = "signup_bonus"
+	TransferGameSettlement  TransferKind = "game_settlement"
+	TransferChop            TransferKind = "chop"
+	TransferServerFee       TransferKind = "server_fee"
+	TransferReward          TransferKind = "reward"
+	TransferAdminAdjustment TransferKind = "admin_adjustment"
+)
+
+// GoldTransaction is a single ledger entry on one user's account.
+type GoldTransaction struct {
+	ID           int64        `json:"id"`
+	TransferID   int64        `json:"transfer_id"`
+	Kind         TransferKind `json:"kind"`
+	Reference    string       `json:"reference,omitempty"`
+	Memo         string       `json:"memo,omitempty"`
+	Amount       int64        `json:"amount"`
+	BalanceAfter int64        `json:"balance_after"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+// ChopPayment moves a chop's gold from the chopped player to the chopper
+// when the chop is made. Index numbers a game's chops from 0. Bot seats are
+// funded by the house.
+type ChopPayment struct {
+	GameID     string
+	RoomID     int
+	Index      int
+	FromUserID int64
+	FromBot    bool
+	ToUserID   int64
+	ToBot      bool
+	Amount     int64
+}
+
+// EscrowHold reserves gold from a player's available balance for one game.
+type EscrowHold struct {
+	UserID int64
+	Amount int64
+}
+
+// InsufficientGoldError reports that a user cannot cover an escrow hold.
+type InsufficientGoldError struct {
+	UserID    int64
+	Available int64
+	Required  int64
+}
+
+func (e *InsufficientGoldError) Error() string {
+	return fmt.Sprintf("user %d has %d gold available, %d required", e.UserID, e.Available, e.Required)
+}
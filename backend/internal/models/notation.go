@@ -0,0 +1,503 @@
+package models
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cards are written rank then suit, as in "3S", "10H" or "AD", and a hand
+// or combination as cards separated by spaces: "7S 7C 7H".
+//
+// A whole game is written like a chess PGN: tag pairs describing the table,
+// the deal and the result, then the moves numbered from 1, one per line.
+//
+//	[Game "tien_len"]
+//	[Variant "mien_nam"]
+//	[Seats "2"]
+//	[Date "2026-01-02T15:04:05Z"]
+//	[FirstSeat "0"]
+//	[OpeningCard "3S"]
+//	[Player0 "an"]
+//	[Hand0 "3S 3C 4S 5S 6S 7S 8S 9S 10S JS QS KS 2S"]
+//	[Player1 "binh"]
+//	[Hand1 "4C 4D 5C 5D 6C 6D 8H 9H 10H JH QH KH AH"]
+//	[Winner "0"]
+//	[Reason "cards_out"]
+//	[Gold0 "+90"]
+//	[Gold1 "-100"]
+//
+//	1. 0: 3S 4S 5S 6S 7S 8S 9S 10S JS QS KS {+2s}
+//	2. 1: pass {+3.5s}
+//	3. 0: 3C
+//
+// A move is its number, the seat, and either the cards played or pass,
+// timeout or declare_sam. The time since the deal may follow in braces;
+// any other text in braces, and lines starting with ';', are comments.
+// Tags the parser does not know are ignored, and tags left out take their
+// defaults: the default rules with the instant wins of the game played,
+// one seat per hand, and seat 0 first.
+// Only the winner, reason, pot, fee, finishing order, chops and each seat's
+// gold and chop gold of a settlement are written. A chop is its chopper,
+// chopped seat, chain, value and amount, then the chopped cards and the
+// bomb, as in [Chop1 "1 0 1 200 200 | 2S | AS AC AD AH"]. SeedHash, ServerSeed and ClientSeed0 onwards
+// hold the seeds the game was dealt from.
+
+func (c Card) String() string {
+	return c.Rank.String() + c.Suit.String()
+}
+
+// ParseCard reads a card such as "10H". Lower case is accepted.
+func ParseCard(s string) (Card, error) {
+	s = strings.ToUpper(s)
+	if len(s) < 2 {
+		return Card{}, fmt.Errorf("invalid card: %q", s)
+	}
+	rank, err := ParseRank(s[:len(s)-1])
+	if err != nil {
+		return Card{}, err
+	}
+	suit, err := ParseSuit(s[len(s)-1:])
+	if err != nil {
+		return Card{}, err
+	}
+	return Card{Rank: rank, Suit: suit}, nil
+}
+
+// ParseCards reads cards separated by spaces, such as "3S 3C 4D".
+func ParseCards(s string) ([]Card, error) {
+	var cards []Card
+	for _, f := range strings.Fields(s) {
+		c, err := ParseCard(f)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, c)
+	}
+	return cards, nil
+}
+
+// FormatCards writes cards separated by spaces, in the order given.
+func FormatCards(cards []Card) string {
+	parts := make([]string, len(cards))
+	for i, c := range cards {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+// FormatGame writes a recorded game in notation.
+func FormatGame(rep *GameReplay) string {
+	g := &rep.Game
+	var b strings.Builder
+	tag := func(name string, value interface{}) {
+		fmt.Fprintf(&b, "[%s %s]\n", name, strconv.Quote(fmt.Sprint(value)))
+	}
+
+	tag("Game", g.Rules.Game)
+	tag("Variant", g.Rules.Variant)
+	if g.Rules.FullRanking {
+		tag("FullRanking", true)
+	}
+	wins := make([]string, len(g.Rules.InstantWins))
+	for i, w := range g.Rules.InstantWins {
+		wins[i] = string(w)
+	}
+	tag("InstantWins", strings.Join(wins, " "))
+	if g.GameID != "" {
+		tag("GameID", g.GameID)
+	}
+	if g.RoomID != 0 {
+		tag("Room", g.RoomID)
+	}
+	if g.AnteAmount != 0 {
+		tag("Ante", g.AnteAmount)
+	}
+	tag("Seats", g.Seats)
+	if !g.StartedAt.IsZero() {
+		tag("Date", g.StartedAt.UTC().Format(time.RFC3339Nano))
+	}
+	tag("FirstSeat", g.FirstSeat)
+	if g.OpeningCard != nil {
+		tag("OpeningCard", g.OpeningCard)
+	}
+	for _, p := range g.Players {
+		tag(fmt.Sprint("Player", p.Seat), p.Username)
+		if p.UserID != 0 {
+			tag(fmt.Sprint("UserID", p.Seat), p.UserID)
+		}
+		if p.IsBot {
+			tag(fmt.Sprint("Bot", p.Seat), true)
+		}
+		tag(fmt.Sprint("Hand", p.Seat), FormatCards(p.Hand))
+	}
+	if d := g.Deck; d != nil {
+		tag("SeedHash", d.SeedHash)
+		if d.ServerSeed != "" {
+			tag("ServerSeed", d.ServerSeed)
+		}
+		for seat, seed := range d.ClientSeeds {
+			tag(fmt.Sprint("ClientSeed", seat), seed)
+		}
+	}
+
+	if rep.Status != "" {
+		tag("Status", rep.Status)
+	}
+	if rep.EndedAt != nil {
+		tag("Ended", rep.EndedAt.UTC().Format(time.RFC3339Nano))
+	}
+	if s := rep.Settlement; s != nil {
+		tag("Winner", s.Winner)
+		tag("Reason", s.Reason)
+		if s.InstantWin != "" {
+			tag("InstantWin", s.InstantWin)
+		}
+		tag("Pot", s.TotalPot)
+		tag("Fee", s.ServerFee)
+		if len(s.FinishOrder) > 0 {
+			order := make([]string, len(s.FinishOrder))
+			for i, seat := range s.FinishOrder {
+				order[i] = strconv.Itoa(seat)
+			}
+			tag("FinishOrder", strings.Join(order, " "))
+		}
+		for i, c := range s.Chops {
+			tag(fmt.Sprint("Chop", i+1), fmt.Sprintf("%d %d %d %d %d | %s | %s",
+				c.Chopper, c.Chopped, c.Chain, c.Value, c.Amount, FormatCards(c.ChoppedCards), FormatCards(c.Cards)))
+		}
+		for _, r := range s.Results {
+			if r != nil {
+				tag(fmt.Sprint("Gold", r.Seat), fmt.Sprintf("%+d", r.GoldDelta))
+				if r.ChopDelta != 0 {
+					tag(fmt.Sprint("ChopGold", r.Seat), fmt.Sprintf("%+d", r.ChopDelta))
+				}
+			}
+		}
+	}
+
+	b.WriteString("\n")
+	for i, m := range rep.Moves {
+		fmt.Fprintf(&b, "%d. %d: ", i+1, m.Seat)
+		if m.Action == MovePlay {
+			b.WriteString(FormatCards(m.Cards))
+		} else {
+			b.WriteString(string(m.Action))
+		}
+		if !g.StartedAt.IsZero() && !m.At.IsZero() {
+			fmt.Fprintf(&b, " {+%s}", m.At.Sub(g.StartedAt))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// ParseGame reads a game written in notation. Moves are numbered from 1 in
+// the order read; their legality is not checked.
+func ParseGame(text string) (*GameReplay, error) {
+	rep := &GameReplay{Moves: []GameMove{}}
+	g := &rep.Game
+	g.Rules = DefaultRoomRules()
+	g.Seats = -1
+
+	tags := make(map[string]string)
+	var moves []string
+	var moveLines []int
+	sc := bufio.NewScanner(strings.NewReader(text))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "["):
+			if len(moves) > 0 {
+				return nil, fmt.Errorf("line %d: tag after the moves", n)
+			}
+			name, value, ok := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"), " ")
+			if !ok || !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed tag", n)
+			}
+			v, err := strconv.Unquote(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: tag %s: %v", n, name, err)
+			}
+			tags[name] = v
+		default:
+			moves = append(moves, line)
+			moveLines = append(moveLines, n)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	atoi := func(name string, dst *int) {
+		if v, ok := tags[name]; ok && err == nil {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				err = fmt.Errorf("tag %s: %v", name, err)
+			}
+		}
+	}
+	timeTag := func(name string) *time.Time {
+		v, ok := tags[name]
+		if !ok || err != nil {
+			return nil
+		}
+		t, perr := time.Parse(time.RFC3339Nano, v)
+		if perr != nil {
+			err = fmt.Errorf("tag %s: %v", name, perr)
+			return nil
+		}
+		return &t
+	}
+
+	if v, ok := tags["Game"]; ok {
+		g.Rules.Game = GameType(v)
+		g.Rules.InstantWins = DefaultInstantWins(g.Rules.Game)
+	}
+	if v, ok := tags["Variant"]; ok {
+		g.Rules.Variant = Variant(v)
+	}
+	g.Rules.FullRanking = tags["FullRanking"] == "true"
+	if v, ok := tags["InstantWins"]; ok {
+		g.Rules.InstantWins = []InstantWin{}
+		for _, w := range strings.Fields(v) {
+			g.Rules.InstantWins = append(g.Rules.InstantWins, InstantWin(w))
+		}
+	}
+	g.GameID = tags["GameID"]
+	atoi("Room", &g.RoomID)
+	atoi("Ante", &g.AnteAmount)
+	atoi("Seats", &g.Seats)
+	atoi("FirstSeat", &g.FirstSeat)
+	if t := timeTag("Date"); t != nil {
+		g.StartedAt = *t
+	}
+	rep.EndedAt = timeTag("Ended")
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := tags["OpeningCard"]; ok {
+		c, err := ParseCard(v)
+		if err != nil {
+			return nil, fmt.Errorf("tag OpeningCard: %v", err)
+		}
+		g.OpeningCard = &c
+	}
+
+	for seat := 0; seat < MaxSeats; seat++ {
+		hand, ok := tags[fmt.Sprint("Hand", seat)]
+		if !ok {
+			break
+		}
+		p := GamePlayerRecord{
+			Seat:     seat,
+			Username: tags[fmt.Sprint("Player", seat)],
+			IsBot:    tags[fmt.Sprint("Bot", seat)] == "true",
+		}
+		if v, ok := tags[fmt.Sprint("UserID", seat)]; ok {
+			if p.UserID, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return nil, fmt.Errorf("tag UserID%d: %v", seat, err)
+			}
+		}
+		if p.Hand, err = ParseCards(hand); err != nil {
+			return nil, fmt.Errorf("tag Hand%d: %v", seat, err)
+		}
+		g.Players = append(g.Players, p)
+	}
+	if g.Seats < 0 {
+		g.Seats = len(g.Players)
+	}
+	if v, ok := tags["SeedHash"]; ok {
+		g.Deck = &DeckProof{SeedHash: v, ServerSeed: tags["ServerSeed"], ClientSeeds: []string{}}
+		for seat := 0; seat < MaxSeats; seat++ {
+			seed, ok := tags[fmt.Sprint("ClientSeed", seat)]
+			if !ok {
+				break
+			}
+			g.Deck.ClientSeeds = append(g.Deck.ClientSeeds, seed)
+		}
+	}
+
+	for i, line := range moves {
+		m, err := parseMove(line, i+1, g.StartedAt)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", moveLines[i], err)
+		}
+		m.GameID = g.GameID
+		rep.Moves = append(rep.Moves, m)
+	}
+
+	rep.Status = GameStatus(tags["Status"])
+	if _, ok := tags["Winner"]; ok {
+		rep.Settlement, err = parseResult(tags, rep)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rep.Status == "" {
+		rep.Status = GameInProgress
+		if rep.Settlement != nil {
+			rep.Status = GameFinished
+		}
+	}
+	return rep, nil
+}
+
+// parseMove reads a move line such as "3. 1: 7S 7C {+4s}", which must be
+// move number seq.
+func parseMove(line string, seq int, start time.Time) (GameMove, error) {
+	m := GameMove{Seq: seq, Action: MovePlay}
+
+	var comments []string
+	for {
+		open := strings.Index(line, "{")
+		if open < 0 {
+			break
+		}
+		end := strings.Index(line[open:], "}")
+		if end < 0 {
+			return m, fmt.Errorf("unclosed comment")
+		}
+		comments = append(comments, strings.TrimSpace(line[open+1:open+end]))
+		line = line[:open] + " " + line[open+end+1:]
+	}
+	for _, c := range comments {
+		if !strings.HasPrefix(c, "+") {
+			continue
+		}
+		d, err := time.ParseDuration(c[1:])
+		if err != nil {
+			return m, fmt.Errorf("bad move time %q: %v", c, err)
+		}
+		m.At = start.Add(d)
+	}
+
+	num, rest, ok := strings.Cut(line, ".")
+	if !ok {
+		return m, fmt.Errorf("move has no number")
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(num)); err != nil || n != seq {
+		return m, fmt.Errorf("move numbered %q, want %d", strings.TrimSpace(num), seq)
+	}
+	seat, action, ok := strings.Cut(rest, ":")
+	if !ok {
+		return m, fmt.Errorf("move %d has no seat", seq)
+	}
+	var err error
+	if m.Seat, err = strconv.Atoi(strings.TrimSpace(seat)); err != nil {
+		return m, fmt.Errorf("move %d: bad seat %q", seq, strings.TrimSpace(seat))
+	}
+
+	switch action = strings.TrimSpace(action); MoveAction(action) {
+	case MovePass, MoveTimeout, MoveDeclareSam:
+		m.Action = MoveAction(action)
+	default:
+		if m.Cards, err = ParseCards(action); err != nil {
+			return m, fmt.Errorf("move %d: %v", seq, err)
+		}
+		if len(m.Cards) == 0 {
+			return m, fmt.Errorf("move %d is empty", seq)
+		}
+	}
+	return m, nil
+}
+
+// parseResult reads the settlement tags of a game.
+func parseResult(tags map[string]string, rep *GameReplay) (*Settlement, error) {
+	g := &rep.Game
+	s := &Settlement{
+		GameID:     g.GameID,
+		RoomID:     g.RoomID,
+		Reason:     SettleReason(tags["Reason"]),
+		InstantWin: InstantWin(tags["InstantWin"]),
+		Results:    make([]*SettlementResult, len(g.Players)),
+		Deck:       g.Deck,
+	}
+	for name, dst := range map[string]*int{"Winner": &s.Winner, "Pot": &s.TotalPot, "Fee": &s.ServerFee} {
+		if v, ok := tags[name]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("tag %s: %v", name, err)
+			}
+			*dst = n
+		}
+	}
+	for _, f := range strings.Fields(tags["FinishOrder"]) {
+		seat, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("tag FinishOrder: %v", err)
+		}
+		s.FinishOrder = append(s.FinishOrder, seat)
+	}
+	for i := 1; ; i++ {
+		v, ok := tags[fmt.Sprint("Chop", i)]
+		if !ok {
+			break
+		}
+		c, err := parseChop(v)
+		if err != nil {
+			return nil, fmt.Errorf("tag Chop%d: %v", i, err)
+		}
+		s.Chops = append(s.Chops, c)
+	}
+
+	for i, p := range g.Players {
+		r := &SettlementResult{Seat: p.Seat, UserID: p.UserID, Username: p.Username, IsBot: p.IsBot}
+		if v, ok := tags[fmt.Sprint("Gold", p.Seat)]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("tag Gold%d: %v", p.Seat, err)
+			}
+			r.GoldDelta = n
+		}
+		if v, ok := tags[fmt.Sprint("ChopGold", p.Seat)]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("tag ChopGold%d: %v", p.Seat, err)
+			}
+			r.ChopDelta = n
+		}
+		s.Results[i] = r
+	}
+	if s.InstantWin != "" && s.Winner >= 0 && s.Winner < len(g.Players) {
+		s.WinningHand = g.Players[s.Winner].Hand
+	}
+	for _, m := range rep.Moves {
+		if m.Action == MoveDeclareSam {
+			seat := m.Seat
+			s.SamDeclarer = &seat
+		}
+	}
+	return s, nil
+}
+
+// parseChop reads a chop such as "1 0 1 200 200 | 2S | AS AC AD AH".
+func parseChop(v string) (Chop, error) {
+	var c Chop
+	parts := strings.Split(v, "|")
+	if len(parts) != 3 {
+		return c, fmt.Errorf("want numbers, chopped cards and bomb separated by |")
+	}
+	nums := strings.Fields(parts[0])
+	dsts := []*int{&c.Chopper, &c.Chopped, &c.Chain, &c.Value, &c.Amount}
+	if len(nums) != len(dsts) {
+		return c, fmt.Errorf("want chopper, chopped, chain, value and amount")
+	}
+	for i, n := range nums {
+		var err error
+		if *dsts[i], err = strconv.Atoi(n); err != nil {
+			return c, err
+		}
+	}
+	var err error
+	if c.ChoppedCards, err = ParseCards(parts[1]); err != nil {
+		return c, err
+	}
+	if c.Cards, err = ParseCards(parts[2]); err != nil {
+		return c, err
+	}
+	return c, nil
+}
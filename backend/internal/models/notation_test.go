@@ -0,0 +1,148 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCards(t *testing.T) {
+	cards, err := ParseCards(" 3S 10h  AD\t2C ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Card{{Three, Spades}, {Ten, Hearts}, {Ace, Diamonds}, {Two, Clubs}}
+	if !reflect.DeepEqual(cards, want) {
+		t.Fatalf("ParseCards = %v, want %v", cards, want)
+	}
+	if s := FormatCards(cards); s != "3S 10H AD 2C" {
+		t.Errorf("FormatCards = %q", s)
+	}
+
+	for _, bad := range []string{"3", "1S", "3X", "10", "JJ", "3S 4"} {
+		if _, err := ParseCards(bad); err == nil {
+			t.Errorf("ParseCards(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestGameNotationRoundTrip(t *testing.T) {
+	start := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	ended := start.Add(20 * time.Second)
+	declarer := 1
+	deck := NewDeckProof(NewDeckSeed(NewSeededRand(1)), []string{"", `my "seed"`, ""})
+	rep := &GameReplay{
+		Game: GameRecord{
+			GameID:     "g-1",
+			RoomID:     7,
+			Rules:      RoomRules{Game: GameSamLoc, Variant: VariantSamLoc, InstantWins: []InstantWin{InstantWinSamDragon}, FullRanking: true},
+			Seats:      3,
+			AnteAmount: 100,
+			FirstSeat:  2,
+			Players: []GamePlayerRecord{
+				{Seat: 0, UserID: 11, Username: `an "the ace"`, Hand: mustCards(t, "3S 4S 5S 6S 7S 8S 9S 10S JS QS")},
+				{Seat: 1, UserID: 12, Username: "bình", Hand: mustCards(t, "3C 4C 5C 6C 7C 8C 9C 10C JC QC")},
+				{Seat: 2, UserID: -1, Username: "bot", IsBot: true, Hand: mustCards(t, "3D 4D 5D 6D 7D 8D 9D 10D JD QD")},
+			},
+			StartedAt: start,
+			Deck:      deck,
+		},
+		Status: GameFinished,
+		Moves: []GameMove{
+			{GameID: "g-1", Seq: 1, Seat: 1, Action: MoveDeclareSam, At: start.Add(time.Second)},
+			{GameID: "g-1", Seq: 2, Seat: 1, Action: MovePlay, Cards: mustCards(t, "3C 4C 5C"), At: start.Add(1500 * time.Millisecond)},
+			{GameID: "g-1", Seq: 3, Seat: 2, Action: MovePass, At: start.Add(4 * time.Second)},
+			{GameID: "g-1", Seq: 4, Seat: 0, Action: MoveTimeout, At: start.Add(19 * time.Second)},
+		},
+		Settlement: &Settlement{
+			GameID:    "g-1",
+			RoomID:    7,
+			Winner:    1,
+			Reason:    SettleSam,
+			TotalPot:  4000,
+			ServerFee: 400,
+			Results: []*SettlementResult{
+				{Seat: 0, UserID: 11, Username: `an "the ace"`, GoldDelta: -2000, ChopDelta: -500},
+				{Seat: 1, UserID: 12, Username: "bình", GoldDelta: 3600, ChopDelta: 500},
+				{Seat: 2, UserID: -1, Username: "bot", IsBot: true, GoldDelta: -2000},
+			},
+			Chops: []Chop{
+				{Chopper: 1, Chopped: 0, Cards: mustCards(t, "6S 6C 6D 6H"), ChoppedCards: mustCards(t, "2S"), Chain: 1, Value: 500, Amount: 500},
+			},
+			FinishOrder: []int{1, 0, 2},
+			SamDeclarer: &declarer,
+			Deck:        deck,
+		},
+		EndedAt: &ended,
+	}
+
+	text := FormatGame(rep)
+	got, err := ParseGame(text)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, text)
+	}
+	if !reflect.DeepEqual(got, rep) {
+		t.Errorf("round trip changed the game:\n%s\ngot  %+v\nwant %+v", text, got, rep)
+	}
+	if again := FormatGame(got); again != text {
+		t.Errorf("formatting again gave\n%s\nwant\n%s", again, text)
+	}
+}
+
+func TestParseGameDefaults(t *testing.T) {
+	rep, err := ParseGame(`
+; a hand pasted from a ticket
+[Hand0 "3S 3C 4S 5S 6S 7S 8S 9S 10S JS QS KS 2S"]
+[Hand1 "4C 4D 5C 5D 6C 6D 8H 9H 10H JH QH KH AH"]
+[Source "ticket 12"]
+
+1. 0: 3s {check the lead}
+2. 1: 4C
+3. 0: pass
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := rep.Game
+	if g.Seats != 2 || g.FirstSeat != 0 || !reflect.DeepEqual(g.Rules, DefaultRoomRules()) || rep.Status != GameInProgress || rep.Settlement != nil {
+		t.Errorf("game = %+v", rep)
+	}
+	want := []GameMove{
+		{Seq: 1, Seat: 0, Action: MovePlay, Cards: mustCards(t, "3S")},
+		{Seq: 2, Seat: 1, Action: MovePlay, Cards: mustCards(t, "4C")},
+		{Seq: 3, Seat: 0, Action: MovePass},
+	}
+	if !reflect.DeepEqual(rep.Moves, want) {
+		t.Errorf("moves = %+v, want %+v", rep.Moves, want)
+	}
+}
+
+func TestParseGameErrors(t *testing.T) {
+	const hands = "[Hand0 \"3S\"]\n[Hand1 \"4S\"]\n"
+	for name, text := range map[string]string{
+		"unquoted tag":     "[Seats 2]\n",
+		"bad number":       "[Seats \"two\"]\n" + hands,
+		"bad date":         "[Date \"yesterday\"]\n" + hands,
+		"bad hand":         "[Hand0 \"3S 3Z\"]\n",
+		"tag after moves":  hands + "1. 0: 3S\n[Winner \"0\"]\n",
+		"misnumbered move": hands + "1. 0: 3S\n3. 1: 4S\n",
+		"no seat":          hands + "1. 3S\n",
+		"empty move":       hands + "1. 0:\n",
+		"bad move time":    hands + "1. 0: 3S {+soon}\n",
+		"bad chop":         hands + "[Winner \"0\"]\n[Chop1 \"1 0 200 | 2S | 6S 6C 6D 6H\"]\n",
+		"unclosed comment": hands + "1. 0: 3S {+1s\n",
+	} {
+		if _, err := ParseGame(text); err == nil {
+			t.Errorf("%s: parsed", name)
+		}
+	}
+}
+
+func mustCards(t *testing.T, s string) []Card {
+	t.Helper()
+	cards, err := ParseCards(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cards
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"crypto/rand"
+	"math/big"
+	mrand "math/rand"
+	"sync"
+)
+
+// Rand is a source of random numbers for shuffling and bot decisions.
+type Rand interface {
+	// Intn returns a uniform random number in [0, n).
+	Intn(n int) int
+}
+
+// CryptoRand draws from crypto/rand. It is the production default.
+type CryptoRand struct{}
+
+func (CryptoRand) Intn(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(err)
+	}
+	return int(v.Int64())
+}
+
+// SeededRand is a deterministic Rand for tests, replays and simulations.
+// It is safe for concurrent use, though the numbers each caller sees then
+// depend on the order of the calls.
+type SeededRand struct {
+	mu sync.Mutex
+	r  *mrand.Rand
+}
+
+func NewSeededRand(seed int64) *SeededRand {
+	return &SeededRand{r: mrand.New(mrand.NewSource(seed))}
+}
+
+func (s *SeededRand) Intn(n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.r.Intn(n)
+}
@@ -1,9 +1,6 @@
 package models
 
-import (
-	"sync"
-	"time"
-)
+import "time"
 
 type GamePhase string
 
@@ -11,6 +8,7 @@ const (
 	PhaseLobby      GamePhase = "LOBBY"
 	PhaseReady      GamePhase = "READY"
 	PhaseDealing    GamePhase = "DEALING"
+	PhaseDeclaring  GamePhase = "DECLARING" // Sâm Lốc: players may báo sâm
 	PhasePlaying    GamePhase = "PLAYING"
 	PhaseSettlement GamePhase = "SETTLEMENT"
 )
@@ -18,7 +16,7 @@ const (
 type CombinationType int
 
 const (
-	ComboSingle         CombinationType = iota
+	ComboSingle CombinationType = iota
 	ComboPair
 	ComboTriple
 	ComboSequence
@@ -34,6 +32,22 @@ type Player struct {
 	SeatIndex int    `json:"seat_index"`
 	IsReady   bool   `json:"is_ready"`
 	IsBot     bool   `json:"is_bot"`
+
+	// Disconnected players keep their seat and hand until the game ends.
+	Disconnected bool `json:"disconnected"`
+
+	// AutoPlay is set while a bot plays for an absent human. ResumePending
+	// hands control back at the next turn boundary.
+	AutoPlay      bool `json:"auto_play"`
+	ResumePending bool `json:"-"`
+	MissedTurns   int  `json:"-"`
+
+	// TimeBank is what is left of the player's extra time this game.
+	TimeBank time.Duration `json:"-"`
+
+	// ClientSeed is the entropy the player added to the next deal when
+	// they readied.
+	ClientSeed string `json:"-"`
 }
 
 type Spectator struct {
@@ -47,41 +61,89 @@ type TablePlay struct {
 	ComboType   CombinationType `json:"combo_type"`
 }
 
+// Room is a table's state. It is owned by the room's goroutine in the hub,
+// which applies every change in turn, so it carries no lock of its own.
 type Room struct {
-	mu           sync.RWMutex
 	ID           int          `json:"id"`
+	GameID       string       `json:"game_id,omitempty"`
 	Name         string       `json:"name"`
 	AnteAmount   int          `json:"ante_amount"`
 	Phase        GamePhase    `json:"phase"`
+	Seats        int          `json:"seats"`
 	Players      [4]*Player   `json:"players"`
 	Spectators   []*Spectator `json:"spectators"`
 	CurrentTurn  int          `json:"current_turn"`
 	TablePlay    *TablePlay   `json:"table_play"`
 	PassCount    int          `json:"pass_count"`
+	RoundPassed  [4]bool      `json:"round_passed"`
+	FinishOrder  []int        `json:"finish_order"`
+	Chops        []Chop       `json:"chops"`
+	LastChop     *Chop        `json:"-"`
 	Winner       int          `json:"winner"`
-	TurnTimer    int          `json:"turn_timer"`
+	TurnTimer    int          `json:"turn_timer"` // seconds per turn
+	TimeBank     int          `json:"time_bank"`  // seconds per player per game
 	HasBots      bool         `json:"has_bots"`
+	Rules        RoomRules    `json:"rules"`
 	WaitingSince *time.Time   `json:"-"`
+
+	// GamesPlayed and LastWinnerID carry the table's history across games
+	// so the previous winner can lead the next one.
+	GamesPlayed  int   `json:"games_played"`
+	LastWinnerID int64 `json:"last_winner_id,omitempty"`
+	// OpeningCard is set until the first play of a table's opening game,
+	// which must include it.
+	OpeningCard *Card `json:"opening_card,omitempty"`
+
+	// Sâm Lốc declarations. SamPending marks seats yet to decide during
+	// PhaseDeclaring; SamDeclarer is the seat that báo sâm, or -1.
+	SamPending  [4]bool `json:"-"`
+	SamDeclarer int     `json:"sam_declarer"`
+
+	// TurnDeadline is when the current turn runs out. BankSince is set
+	// while the seat on turn is drawing on their time bank.
+	TurnDeadline time.Time `json:"-"`
+	BankSince    time.Time `json:"-"`
+
+	// DeckSeed is the server seed committed to for the next deal, and
+	// Deck the proof of the deal in play, revealed at settlement.
+	DeckSeed []byte     `json:"-"`
+	Deck     *DeckProof `json:"-"`
 }
 
 const MaxSpectators = 3
 
+// A table seats between MinSeats and MaxSeats players. Players is always
+// MaxSeats long; seats at or beyond Seats stay empty.
+const (
+	MinSeats = 2
+	MaxSeats = 4
+)
+
+// Turn clock defaults, in seconds. A player whose turn runs out draws on
+// their time bank before the turn is passed for them.
+const (
+	DefaultTurnTimer = 30
+	DefaultTimeBank  = 30
+	SpeedTurnTimer   = 10
+	SpeedTimeBank    = 10
+)
+
 func NewRoom(id int, name string, ante int) *Room {
 	return &Room{
-		ID:         id,
-		Name:       name,
-		AnteAmount: ante,
-		Phase:      PhaseLobby,
-		Spectators: make([]*Spectator, 0, MaxSpectators),
-		Winner:     -1,
+		ID:          id,
+		Name:        name,
+		AnteAmount:  ante,
+		Phase:       PhaseLobby,
+		Seats:       MaxSeats,
+		TurnTimer:   DefaultTurnTimer,
+		TimeBank:    DefaultTimeBank,
+		Spectators:  make([]*Spectator, 0, MaxSpectators),
+		Winner:      -1,
+		Rules:       DefaultRoomRules(),
+		SamDeclarer: -1,
 	}
 }
 
-func (r *Room) Lock()    { r.mu.Lock() }
-func (r *Room) Unlock()  { r.mu.Unlock() }
-func (r *Room) RLock()   { r.mu.RLock() }
-func (r *Room) RUnlock() { r.mu.RUnlock() }
-
 func (r *Room) PlayerCount() int {
 	count := 0
 	for _, p := range r.Players {
@@ -93,14 +155,18 @@ func (r *Room) PlayerCount() int {
 }
 
 func (r *Room) FindEmptySeat() int {
-	for i, p := range r.Players {
-		if p == nil {
+	for i := 0; i < r.Seats; i++ {
+		if r.Players[i] == nil {
 			return i
 		}
 	}
 	return -1
 }
 
+func (r *Room) IsFull() bool {
+	return r.PlayerCount() >= r.Seats
+}
+
 func (r *Room) FindPlayerByUserID(userID int64) (int, *Player) {
 	for i, p := range r.Players {
 		if p != nil && p.UserID == userID {
@@ -111,10 +177,10 @@ func (r *Room) FindPlayerByUserID(userID int64) (int, *Player) {
 }
 
 func (r *Room) AllPlayersReady() bool {
-	if r.PlayerCount() != 4 {
+	if r.PlayerCount() != r.Seats {
 		return false
 	}
-	for _, p := range r.Players {
+	for _, p := range r.Players[:r.Seats] {
 		if p == nil || !p.IsReady {
 			return false
 		}
@@ -122,6 +188,25 @@ func (r *Room) AllPlayersReady() bool {
 	return true
 }
 
+// ClearRound empties the table and lets every seat play again.
+func (r *Room) ClearRound() {
+	r.TablePlay = nil
+	r.PassCount = 0
+	r.RoundPassed = [4]bool{}
+	r.LastChop = nil
+}
+
+// PassedSeats lists the seats locked out of the current round.
+func (r *Room) PassedSeats() []int {
+	seats := make([]int, 0, 4)
+	for i, passed := range r.RoundPassed {
+		if passed {
+			seats = append(seats, i)
+		}
+	}
+	return seats
+}
+
 func (r *Room) AddSpectator(s *Spectator) bool {
 	if len(r.Spectators) >= MaxSpectators {
 		return false
@@ -146,20 +231,35 @@ type RoomInfo struct {
 	AnteAmount  int       `json:"ante_amount"`
 	Phase       GamePhase `json:"phase"`
 	PlayerCount int       `json:"player_count"`
+	Seats       int       `json:"seats"`
+	TurnTimer   int       `json:"turn_timer"`
 	Spectators  int       `json:"spectator_count"`
 	HasBots     bool      `json:"has_bots"`
+	Game        GameType  `json:"game"`
+	Variant     Variant   `json:"variant"`
+	// SeedHash commits to the server seed of the next deal while the room
+	// is in the lobby.
+	SeedHash string `json:"seed_hash,omitempty"`
 }
 
 func (r *Room) ToInfo() RoomInfo {
-	return RoomInfo{
+	info := RoomInfo{
 		ID:          r.ID,
 		Name:        r.Name,
 		AnteAmount:  r.AnteAmount,
 		Phase:       r.Phase,
 		PlayerCount: r.PlayerCount(),
+		Seats:       r.Seats,
+		TurnTimer:   r.TurnTimer,
 		Spectators:  len(r.Spectators),
 		HasBots:     r.HasBots,
+		Game:        r.Rules.Game,
+		Variant:     r.Rules.Variant,
+	}
+	if r.DeckSeed != nil && r.Phase == PhaseLobby {
+		info.SeedHash = SeedHash(r.DeckSeed)
 	}
+	return info
 }
 
 func (r *Room) HumanPlayerCount() int {
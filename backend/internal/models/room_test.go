@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToInfoNamesTheGame(t *testing.T) {
+	tests := []struct {
+		rules RoomRules
+		want  string
+	}{
+		{DefaultRoomRules(), `"game":"tien_len","variant":"mien_nam"`},
+		{RoomRules{Game: GameSamLoc, Variant: VariantSamLoc}, `"game":"sam_loc","variant":"sam_loc"`},
+	}
+	for _, tt := range tests {
+		room := NewRoom(1, "test", 100)
+		room.Rules = tt.rules
+		data, err := json.Marshal(room.ToInfo())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), tt.want) {
+			t.Errorf("ToInfo() = %s, want it to contain %s", data, tt.want)
+		}
+	}
+}
+
+func TestDefaultInstantWinsFitTheGame(t *testing.T) {
+	tests := []struct {
+		game GameType
+		want []InstantWin
+	}{
+		{GameTienLen, []InstantWin{InstantWinDragon, InstantWinFourTwos, InstantWinFiveConsecPairs, InstantWinSixPairs, InstantWinFourTriples}},
+		{GameSamLoc, []InstantWin{InstantWinFourTwos, InstantWinSamDragon, InstantWinFivePairs, InstantWinThreeTriples, InstantWinOneColour}},
+	}
+	for _, tt := range tests {
+		if got := DefaultInstantWins(tt.game); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DefaultInstantWins(%s) = %v, want %v", tt.game, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package models
+
+// InstantWin is a dealt hand that wins the game on the spot (tới trắng).
+type InstantWin string
+
+const (
+	InstantWinDragon          InstantWin = "dragon"                 // 3 through A
+	InstantWinFourTwos        InstantWin = "four_twos"              // all four 2s
+	InstantWinFiveConsecPairs InstantWin = "five_consecutive_pairs" // e.g. 33-44-55-66-77
+	InstantWinSixPairs        InstantWin = "six_pairs"              // any six pairs
+	InstantWinFourTriples     InstantWin = "four_triples"           // any four triples
+
+	// Sâm Lốc (10-card hands)
+	InstantWinSamDragon    InstantWin = "sam_dragon"    // ten consecutive ranks, no 2
+	InstantWinFivePairs    InstantWin = "five_pairs"    // any five pairs
+	InstantWinThreeTriples InstantWin = "three_triples" // any three triples
+	InstantWinOneColour    InstantWin = "one_colour"    // all red or all black
+)
+
+// AllInstantWins lists every instant win, strongest first. When more than
+// one player is dealt an instant win, the strongest hand takes the game.
+var AllInstantWins = []InstantWin{
+	InstantWinDragon,
+	InstantWinFourTwos,
+	InstantWinFiveConsecPairs,
+	InstantWinSixPairs,
+	InstantWinFourTriples,
+	InstantWinSamDragon,
+	InstantWinFivePairs,
+	InstantWinThreeTriples,
+	InstantWinOneColour,
+}
+
+// GameType is the card game a table plays.
+type GameType string
+
+const (
+	GameTienLen GameType = "tien_len"
+	GameSamLoc  GameType = "sam_loc"
+)
+
+// Variant names the Tien Len rule set a table plays by.
+type Variant string
+
+const (
+	VariantMienNam Variant = "mien_nam" // Southern rules
+	VariantMienBac Variant = "mien_bac" // Northern rules: follow suit and colour
+	VariantSamLoc  Variant = "sam_loc"  // the rule set Sâm Lốc tables play by
+)
+
+// RoomRules are the house rules a table plays by.
+type RoomRules struct {
+	Game GameType `json:"game"`
+	// Variant only applies to Tien Len tables.
+	Variant Variant `json:"variant"`
+	// InstantWins lists the instant-win hands honoured at this table.
+	InstantWins []InstantWin `json:"instant_wins"`
+	// FullRanking plays on until every place (nhất, nhì, ba, bét) is
+	// decided and pays out by place instead of ending at the first winner.
+	FullRanking bool `json:"full_ranking"`
+}
+
+// DefaultInstantWins returns the instant wins a table of game honours unless
+// its room is configured otherwise. Four 2s win at both games; the other
+// hands belong to one.
+func DefaultInstantWins(game GameType) []InstantWin {
+	if game == GameSamLoc {
+		return []InstantWin{InstantWinFourTwos, InstantWinSamDragon, InstantWinFivePairs, InstantWinThreeTriples, InstantWinOneColour}
+	}
+	return []InstantWin{InstantWinDragon, InstantWinFourTwos, InstantWinFiveConsecPairs, InstantWinSixPairs, InstantWinFourTriples}
+}
+
+func DefaultRoomRules() RoomRules {
+	return RoomRules{Game: GameTienLen, Variant: VariantMienNam, InstantWins: DefaultInstantWins(GameTienLen)}
+}
+
+func (r RoomRules) InstantWinEnabled(kind InstantWin) bool {
+	for _, k := range r.InstantWins {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
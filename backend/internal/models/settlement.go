@@ -0,0 +1,97 @@
+package models
+
+// SettlementResult is one seat's outcome in a finished game.
+type SettlementResult struct {
+	Seat              int    `json:"seat"`
+	UserID            int64  `json:"user_id"`
+	Username          string `json:"username"`
+	CardsLeft         int    `json:"cards_left"`
+	TwosHeld          int    `json:"twos_held"`
+	PenaltyMultiplier int    `json:"penalty_multiplier"`
+	GoldDelta         int    `json:"gold_delta"`
+	IsBot             bool   `json:"is_bot"`
+	// Position is the 1-based finishing place in full-ranking games.
+	Position int `json:"position,omitempty"`
+	// Fee is the server fee already deducted from GoldDelta.
+	Fee int `json:"fee,omitempty"`
+	// ChopDelta is the net gold from chops. Chops are paid when they are
+	// made, so it is not part of GoldDelta.
+	ChopDelta int `json:"chop_delta,omitempty"`
+}
+
+// Chop is a penalty paid on the spot when a player's 2s, or a bomb that
+// chopped them, are beaten by a bomb.
+type Chop struct {
+	Chopper      int    `json:"chopper"`
+	Chopped      int    `json:"chopped"`
+	Cards        []Card `json:"cards"`
+	ChoppedCards []Card `json:"chopped_cards"`
+	// Chain is 1 for a chop of 2s and counts up for each chop-over-chop.
+	Chain int `json:"chain"`
+	// Value is what the chop is worth; Amount is what was actually paid
+	// after capping at the chopped player's escrow.
+	Value  int `json:"value"`
+	Amount int `json:"amount"`
+}
+
+// SettleReason says how a game ended.
+type SettleReason string
+
+const (
+	SettleCardsOut   SettleReason = "cards_out"
+	SettleInstantWin SettleReason = "instant_win"
+	SettleRanked     SettleReason = "ranked"
+	SettleSam        SettleReason = "sam"         // the declarer went out unbeaten
+	SettleSamBlocked SettleReason = "sam_blocked" // someone beat the declarer
+)
+
+// Settlement is the gold outcome of a single game. Results is indexed by
+// seat and holds nil for empty seats.
+type Settlement struct {
+	GameID    string              `json:"game_id"`
+	RoomID    int                 `json:"room_id"`
+	Winner    int                 `json:"winner"`
+	Reason    SettleReason        `json:"reason"`
+	Results   []*SettlementResult `json:"results"`
+	ServerFee int                 `json:"server_fee"`
+	TotalPot  int                 `json:"total_pot"`
+
+	// Chops lists every chop in the game, in order.
+	Chops []Chop `json:"chops,omitempty"`
+
+	// FinishOrder lists seats from first to last place in ranked games.
+	FinishOrder []int `json:"finish_order,omitempty"`
+
+	// SamDeclarer is the seat that báo sâm, when Reason is SettleSam or
+	// SettleSamBlocked.
+	SamDeclarer *int `json:"sam_declarer,omitempty"`
+
+	// Set when Reason is SettleInstantWin; the winning hand is revealed.
+	InstantWin  InstantWin `json:"instant_win,omitempty"`
+	WinningHand []Card     `json:"winning_hand,omitempty"`
+
+	// Deck reveals the seeds the game was dealt from.
+	Deck *DeckProof `json:"deck,omitempty"`
+}
+
+// ChopPayments lists the payments for the game's chops, in order.
+func (s *Settlement) ChopPayments() []ChopPayment {
+	payments := make([]ChopPayment, 0, len(s.Chops))
+	for i, c := range s.Chops {
+		from, to := s.Results[c.Chopped], s.Results[c.Chopper]
+		if from == nil || to == nil {
+			continue
+		}
+		payments = append(payments, ChopPayment{
+			GameID:     s.GameID,
+			RoomID:     s.RoomID,
+			Index:      i,
+			FromUserID: from.UserID,
+			FromBot:    from.IsBot,
+			ToUserID:   to.UserID,
+			ToBot:      to.IsBot,
+			Amount:     int64(c.Amount),
+		})
+	}
+	return payments
+}
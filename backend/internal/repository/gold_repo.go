@@ -0,0 +1,378 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+type GoldRepo struct {
+	db *sql.DB
+}
+
+func NewGoldRepo(db *sql.DB) *GoldRepo {
+	return &GoldRepo{db: db}
+}
+
+type ledgerEntry struct {
+	userID int64
+	amount int64
+}
+
+// postTransfer records a double-entry transfer inside tx. The entries must
+// balance to zero; zero-amount entries are dropped and entries for the same
+// account are merged.
+func postTransfer(ctx context.Context, tx *sql.Tx, kind models.TransferKind, reference, memo string, entries []ledgerEntry) error {
+	merged := make(map[int64]int64)
+	var sum int64
+	for _, e := range entries {
+		merged[e.userID] += e.amount
+		sum += e.amount
+	}
+	if sum != 0 {
+		return fmt.Errorf("unbalanced %s transfer %q: entries sum to %d", kind, reference, sum)
+	}
+
+	// Lock accounts in ID order so concurrent transfers cannot deadlock.
+	ids := make([]int64, 0, len(merged))
+	for id, amount := range merged {
+		if amount != 0 {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var transferID int64
+	err := tx.QueryRowContext(ctx,
+		`INSERT INTO gold_transfers (kind, reference, memo) VALUES ($1, $2, $3) RETURNING id`,
+		kind, reference, memo,
+	).Scan(&transferID)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		amount := merged[id]
+		var balance int64
+		err := tx.QueryRowContext(ctx,
+			`UPDATE users SET gold_balance = gold_balance + $1 WHERE id = $2 RETURNING gold_balance`,
+			amount, id,
+		).Scan(&balance)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%s transfer %q: user %d not found", kind, reference, id)
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO gold_transactions (transfer_id, user_id, amount, balance_after) VALUES ($1, $2, $3, $4)`,
+			transferID, id, amount, balance,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplySettlement books a finished game in a single transaction: any of its
+// chops not booked yet are paid first, in order, then losers pay into the
+// pot, winners collect it, and each winner's server fee is moved to the
+// house. Bot seats are funded by the house. The game's escrow holds are
+// released in the same transaction. Settling a game that was already booked
+// is a no-op.
+func (r *GoldRepo) ApplySettlement(ctx context.Context, s *models.Settlement) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var winnerID sql.NullInt64
+	var winner *models.SettlementResult
+	if s.Winner >= 0 && s.Winner < len(s.Results) {
+		winner = s.Results[s.Winner]
+	}
+	if winner != nil && !winner.IsBot {
+		winnerID = sql.NullInt64{Int64: winner.UserID, Valid: true}
+	}
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO game_settlements (game_id, room_id, winner_user_id, total_pot, server_fee)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (game_id) DO NOTHING`,
+		s.GameID, s.RoomID, winnerID, s.TotalPot, s.ServerFee,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return nil
+	}
+
+	entries, fees, err := settlementEntries(s)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range s.ChopPayments() {
+		if err := bookChop(ctx, tx, &p); err != nil {
+			return err
+		}
+	}
+
+	if err := releaseEscrow(ctx, tx, s.GameID); err != nil {
+		return err
+	}
+
+	memo := fmt.Sprintf("room %d", s.RoomID)
+	if err := postTransfer(ctx, tx, models.TransferGameSettlement, s.GameID, memo, entries); err != nil {
+		return err
+	}
+
+	if err := postTransfer(ctx, tx, models.TransferServerFee, s.GameID, memo, fees); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// settlementEntries splits a settlement into its two transfers. The
+// settlement transfer carries gross amounts; the fee is booked as a
+// separate transfer so players can see it on their history. The house
+// takes the other side of every bot seat and collects the fees.
+func settlementEntries(s *models.Settlement) (entries, fees []ledgerEntry, err error) {
+	var sum, humanSum, humanFees int64
+	for _, result := range s.Results {
+		if result == nil {
+			continue
+		}
+		amount := int64(result.GoldDelta + result.Fee)
+		sum += amount
+		if !result.IsBot {
+			entries = append(entries, ledgerEntry{userID: result.UserID, amount: amount})
+			humanSum += amount
+			if result.Fee > 0 {
+				fees = append(fees, ledgerEntry{userID: result.UserID, amount: -int64(result.Fee)})
+				humanFees += int64(result.Fee)
+			}
+		}
+	}
+	if sum != 0 {
+		return nil, nil, fmt.Errorf("settle game %s: results sum to %d", s.GameID, sum)
+	}
+	entries = append(entries, ledgerEntry{userID: models.HouseUserID, amount: -humanSum})
+	fees = append(fees, ledgerEntry{userID: models.HouseUserID, amount: humanFees})
+	return entries, fees, nil
+}
+
+// ApplyChop pays a chop from the chopped player to the chopper in a single
+// transaction, and takes the payment out of the payer's escrow hold so the
+// gold is not counted against them twice. Bot seats are funded by the
+// house. Paying a chop that was already booked is a no-op.
+func (r *GoldRepo) ApplyChop(ctx context.Context, p *models.ChopPayment) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := bookChop(ctx, tx, p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// bookChop pays a chop inside tx unless it was already booked.
+func bookChop(ctx context.Context, tx *sql.Tx, p *models.ChopPayment) error {
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO game_chops (game_id, chop_index, from_user_id, to_user_id, amount)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (game_id, chop_index) DO NOTHING`,
+		p.GameID, p.Index, p.FromUserID, p.ToUserID, p.Amount,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return nil
+	}
+
+	from, to := p.FromUserID, p.ToUserID
+	if p.FromBot {
+		from = models.HouseUserID
+	}
+	if p.ToBot {
+		to = models.HouseUserID
+	}
+	reference := fmt.Sprintf("%s/%d", p.GameID, p.Index)
+	memo := fmt.Sprintf("room %d", p.RoomID)
+	if err := postTransfer(ctx, tx, models.TransferChop, reference, memo, []ledgerEntry{
+		{userID: from, amount: -p.Amount},
+		{userID: to, amount: p.Amount},
+	}); err != nil {
+		return err
+	}
+
+	if !p.FromBot {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE gold_escrows SET amount = GREATEST(amount - $3, 0)
+			 WHERE game_id = $1 AND user_id = $2 AND released_at IS NULL`,
+			p.GameID, p.FromUserID, p.Amount,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AvailableGold returns a user's balance minus gold held in open escrows.
+func (r *GoldRepo) AvailableGold(ctx context.Context, userID int64) (int64, error) {
+	var available int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT u.gold_balance - COALESCE((
+			SELECT SUM(e.amount) FROM gold_escrows e
+			WHERE e.user_id = u.id AND e.released_at IS NULL
+		 ), 0)
+		 FROM users u WHERE u.id = $1`,
+		userID,
+	).Scan(&available)
+	return available, err
+}
+
+// ReserveEscrow places all holds for a game atomically. If any player cannot
+// cover their hold nothing is reserved and an *models.InsufficientGoldError
+// naming that player is returned.
+func (r *GoldRepo) ReserveEscrow(ctx context.Context, gameID string, holds []models.EscrowHold) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sorted := make([]models.EscrowHold, len(holds))
+	copy(sorted, holds)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].UserID < sorted[j].UserID })
+
+	for _, h := range sorted {
+		var balance int64
+		if err := tx.QueryRowContext(ctx,
+			`SELECT gold_balance FROM users WHERE id = $1 FOR UPDATE`, h.UserID,
+		).Scan(&balance); err != nil {
+			return err
+		}
+		var held int64
+		if err := tx.QueryRowContext(ctx,
+			`SELECT COALESCE(SUM(amount), 0) FROM gold_escrows WHERE user_id = $1 AND released_at IS NULL`,
+			h.UserID,
+		).Scan(&held); err != nil {
+			return err
+		}
+		if balance-held < h.Amount {
+			return &models.InsufficientGoldError{UserID: h.UserID, Available: balance - held, Required: h.Amount}
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO gold_escrows (game_id, user_id, amount) VALUES ($1, $2, $3)`,
+			gameID, h.UserID, h.Amount,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ReleaseEscrow frees every open hold for an aborted game.
+func (r *GoldRepo) ReleaseEscrow(ctx context.Context, gameID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := releaseEscrow(ctx, tx, gameID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReleaseAllEscrows frees every open hold. Games only live in memory, so any
+// hold left over from a previous process can never be settled.
+func (r *GoldRepo) ReleaseAllEscrows(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE gold_escrows SET released_at = NOW() WHERE released_at IS NULL`,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func releaseEscrow(ctx context.Context, tx *sql.Tx, gameID string) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE gold_escrows SET released_at = NOW() WHERE game_id = $1 AND released_at IS NULL`,
+		gameID,
+	)
+	return err
+}
+
+// AdjustGold moves gold between a user and the house, e.g. for rewards and
+// admin corrections. A positive amount credits the user.
+func (r *GoldRepo) AdjustGold(ctx context.Context, userID, amount int64, kind models.TransferKind, reference, memo string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := postTransfer(ctx, tx, kind, reference, memo, []ledgerEntry{
+		{userID: userID, amount: amount},
+		{userID: models.HouseUserID, amount: -amount},
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListTransactions returns a user's ledger entries, newest first, along with
+// the total number of entries.
+func (r *GoldRepo) ListTransactions(ctx context.Context, userID int64, limit, offset int) ([]models.GoldTransaction, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM gold_transactions WHERE user_id = $1`, userID,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT g.id, g.transfer_id, t.kind, t.reference, t.memo, g.amount, g.balance_after, g.created_at
+		 FROM gold_transactions g
+		 JOIN gold_transfers t ON t.id = g.transfer_id
+		 WHERE g.user_id = $1
+		 ORDER BY g.id DESC
+		 LIMIT $2 OFFSET $3`,
+		userID, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	txs := make([]models.GoldTransaction, 0, limit)
+	for rows.Next() {
+		var t models.GoldTransaction
+		if err := rows.Scan(&t.ID, &t.TransferID, &t.Kind, &t.Reference, &t.Memo, &t.Amount, &t.BalanceAfter, &t.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		txs = append(txs, t)
+	}
+	return txs, total, rows.Err()
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+func TestSettlementEntries(t *testing.T) {
+	s := &models.Settlement{
+		GameID: "g1",
+		Winner: 0,
+		Results: []*models.SettlementResult{
+			{Seat: 0, UserID: 7, GoldDelta: 270, Fee: 30},
+			{Seat: 1, UserID: 8, GoldDelta: -100, IsBot: true},
+			{Seat: 2, UserID: 9, GoldDelta: -200},
+			nil,
+		},
+	}
+	entries, fees, err := settlementEntries(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The house stands in for the bot seat, and every transfer balances.
+	wantEntries := []ledgerEntry{{7, 300}, {9, -200}, {models.HouseUserID, -100}}
+	wantFees := []ledgerEntry{{7, -30}, {models.HouseUserID, 30}}
+	if !reflect.DeepEqual(entries, wantEntries) {
+		t.Errorf("entries = %v, want %v", entries, wantEntries)
+	}
+	if !reflect.DeepEqual(fees, wantFees) {
+		t.Errorf("fees = %v, want %v", fees, wantFees)
+	}
+	for name, transfer := range map[string][]ledgerEntry{"settlement": entries, "fee": fees} {
+		var sum int64
+		for _, e := range transfer {
+			sum += e.amount
+		}
+		if sum != 0 {
+			t.Errorf("%s transfer sums to %d", name, sum)
+		}
+	}
+}
+
+func TestSettlementEntriesRejectsUnbalancedResults(t *testing.T) {
+	s := &models.Settlement{
+		GameID: "g1",
+		Results: []*models.SettlementResult{
+			{Seat: 0, UserID: 7, GoldDelta: 270, Fee: 30},
+			{Seat: 1, UserID: 8, GoldDelta: -200},
+		},
+	}
+	if _, _, err := settlementEntries(s); err == nil {
+		t.Error("a settlement that creates gold was accepted")
+	}
+}
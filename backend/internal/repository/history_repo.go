@@ -0,0 +1,315 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+type HistoryRepo struct {
+	db *sql.DB
+}
+
+func NewHistoryRepo(db *sql.DB) *HistoryRepo {
+	return &HistoryRepo{db: db}
+}
+
+// CreateGame records a freshly dealt game and its players. Recording the
+// same game twice is a no-op.
+func (r *HistoryRepo) CreateGame(ctx context.Context, g *models.GameRecord) error {
+	rules, err := json.Marshal(g.Rules)
+	if err != nil {
+		return err
+	}
+	var opening, deck []byte
+	if g.OpeningCard != nil {
+		if opening, err = json.Marshal(g.OpeningCard); err != nil {
+			return err
+		}
+	}
+	if g.Deck != nil {
+		if deck, err = json.Marshal(g.Deck); err != nil {
+			return err
+		}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO games (game_id, room_id, rules, seats, ante_amount, first_seat, opening_card, deck_proof, status, started_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 ON CONFLICT (game_id) DO NOTHING`,
+		g.GameID, g.RoomID, rules, g.Seats, g.AnteAmount, g.FirstSeat, opening, deck, models.GameInProgress, g.StartedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return nil
+	}
+
+	for _, p := range g.Players {
+		hand, err := json.Marshal(p.Hand)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO game_players (game_id, seat, user_id, username, is_bot, hand) VALUES ($1, $2, $3, $4, $5, $6)`,
+			g.GameID, p.Seat, p.UserID, p.Username, p.IsBot, hand,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// AddMove records one move of a game.
+func (r *HistoryRepo) AddMove(ctx context.Context, m *models.GameMove) error {
+	var cards []byte
+	if len(m.Cards) > 0 {
+		var err error
+		if cards, err = json.Marshal(m.Cards); err != nil {
+			return err
+		}
+	}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO game_moves (game_id, seq, seat, action, cards, played_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (game_id, seq) DO NOTHING`,
+		m.GameID, m.Seq, m.Seat, m.Action, cards, m.At,
+	)
+	return err
+}
+
+// FinishGame records a game's settlement and each player's result.
+func (r *HistoryRepo) FinishGame(ctx context.Context, s *models.Settlement, at time.Time) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE games SET status = $2, winner_seat = $3, reason = $4, settlement = $5, ended_at = $6
+		 WHERE game_id = $1`,
+		s.GameID, models.GameFinished, s.Winner, s.Reason, data, at,
+	); err != nil {
+		return err
+	}
+	for _, result := range s.Results {
+		if result == nil {
+			continue
+		}
+		var position sql.NullInt64
+		if result.Position > 0 {
+			position = sql.NullInt64{Int64: int64(result.Position), Valid: true}
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE game_players SET position = $3, gold_delta = $4 WHERE game_id = $1 AND seat = $2`,
+			s.GameID, result.Seat, position, result.GoldDelta,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// AbortGame marks a game that ended without a settlement.
+func (r *HistoryRepo) AbortGame(ctx context.Context, gameID string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE games SET status = $2, ended_at = $3 WHERE game_id = $1 AND status = $4`,
+		gameID, models.GameAborted, at, models.GameInProgress,
+	)
+	return err
+}
+
+// ListUserGames returns the games a user sat in, newest first, along with
+// the total number of such games.
+func (r *HistoryRepo) ListUserGames(ctx context.Context, userID int64, limit, offset int) ([]models.GameSummary, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM game_players WHERE user_id = $1`, userID,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT g.game_id, g.room_id, g.rules, g.ante_amount, g.status, g.reason, g.winner_seat,
+		        g.started_at, g.ended_at, p.seat, p.position, p.gold_delta
+		 FROM game_players p
+		 JOIN games g ON g.game_id = p.game_id
+		 WHERE p.user_id = $1
+		 ORDER BY g.started_at DESC, g.game_id
+		 LIMIT $2 OFFSET $3`,
+		userID, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	games := make([]models.GameSummary, 0, limit)
+	index := make(map[string]int)
+	var ids []string
+	for rows.Next() {
+		var g models.GameSummary
+		var rules []byte
+		var winner, position, delta sql.NullInt64
+		var ended sql.NullTime
+		if err := rows.Scan(&g.GameID, &g.RoomID, &rules, &g.AnteAmount, &g.Status, &g.Reason, &winner,
+			&g.StartedAt, &ended, &g.Seat, &position, &delta); err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal(rules, &g.Rules); err != nil {
+			return nil, 0, err
+		}
+		if ended.Valid {
+			g.EndedAt = &ended.Time
+		}
+		g.Won = winner.Valid && int(winner.Int64) == g.Seat && g.Status == models.GameFinished
+		g.Position = int(position.Int64)
+		g.GoldDelta = int(delta.Int64)
+		g.Players = []models.GameSummaryPlayer{}
+		index[g.GameID] = len(games)
+		ids = append(ids, g.GameID)
+		games = append(games, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(ids) == 0 {
+		return games, total, nil
+	}
+
+	rows, err = r.db.QueryContext(ctx,
+		`SELECT game_id, seat, username, is_bot, position, gold_delta
+		 FROM game_players
+		 WHERE game_id = ANY($1)
+		 ORDER BY game_id, seat`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var gameID string
+		var p models.GameSummaryPlayer
+		var position, delta sql.NullInt64
+		if err := rows.Scan(&gameID, &p.Seat, &p.Username, &p.IsBot, &position, &delta); err != nil {
+			return nil, 0, err
+		}
+		p.Position = int(position.Int64)
+		p.GoldDelta = int(delta.Int64)
+		g := &games[index[gameID]]
+		g.Players = append(g.Players, p)
+	}
+	return games, total, rows.Err()
+}
+
+// GetReplay loads a recorded game with its moves in order. It returns nil
+// if there is no such game.
+func (r *HistoryRepo) GetReplay(ctx context.Context, gameID string) (*models.GameReplay, error) {
+	rep := &models.GameReplay{Moves: []models.GameMove{}}
+	g := &rep.Game
+	var rules, opening, deck, settlement []byte
+	var ended sql.NullTime
+	err := r.db.QueryRowContext(ctx,
+		`SELECT game_id, room_id, rules, seats, ante_amount, first_seat, opening_card, deck_proof, status, settlement, started_at, ended_at
+		 FROM games WHERE game_id = $1`,
+		gameID,
+	).Scan(&g.GameID, &g.RoomID, &rules, &g.Seats, &g.AnteAmount, &g.FirstSeat, &opening, &deck, &rep.Status, &settlement, &g.StartedAt, &ended)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rules, &g.Rules); err != nil {
+		return nil, err
+	}
+	if opening != nil {
+		g.OpeningCard = new(models.Card)
+		if err := json.Unmarshal(opening, g.OpeningCard); err != nil {
+			return nil, err
+		}
+	}
+	if deck != nil {
+		g.Deck = new(models.DeckProof)
+		if err := json.Unmarshal(deck, g.Deck); err != nil {
+			return nil, err
+		}
+	}
+	if settlement != nil {
+		rep.Settlement = new(models.Settlement)
+		if err := json.Unmarshal(settlement, rep.Settlement); err != nil {
+			return nil, err
+		}
+	}
+	if ended.Valid {
+		rep.EndedAt = &ended.Time
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT seat, user_id, username, is_bot, hand FROM game_players WHERE game_id = $1 ORDER BY seat`,
+		gameID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p models.GamePlayerRecord
+		var hand []byte
+		if err := rows.Scan(&p.Seat, &p.UserID, &p.Username, &p.IsBot, &hand); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(hand, &p.Hand); err != nil {
+			return nil, err
+		}
+		g.Players = append(g.Players, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.QueryContext(ctx,
+		`SELECT seq, seat, action, cards, played_at FROM game_moves WHERE game_id = $1 ORDER BY seq`,
+		gameID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		m := models.GameMove{GameID: gameID}
+		var cards []byte
+		if err := rows.Scan(&m.Seq, &m.Seat, &m.Action, &cards, &m.At); err != nil {
+			return nil, err
+		}
+		if cards != nil {
+			if err := json.Unmarshal(cards, &m.Cards); err != nil {
+				return nil, err
+			}
+		}
+		rep.Moves = append(rep.Moves, m)
+	}
+	return rep, rows.Err()
+}
@@ -15,16 +15,35 @@ func NewUserRepo(db *sql.DB) *UserRepo {
 	return &UserRepo{db: db}
 }
 
+// Create registers a user and credits the signup bonus from the house so
+// the starting balance shows up in the user's ledger.
 func (r *UserRepo) Create(ctx context.Context, username, passwordHash string) (*models.User, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	user := &models.User{}
-	err := r.db.QueryRowContext(ctx,
-		`INSERT INTO users (username, password_hash) VALUES ($1, $2)
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO users (username, password_hash, gold_balance) VALUES ($1, $2, 0)
 		 RETURNING id, username, password_hash, gold_balance, rank, created_at`,
 		username, passwordHash,
 	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.GoldBalance, &user.Rank, &user.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
+
+	if err := postTransfer(ctx, tx, models.TransferSignupBonus, "", "welcome gold", []ledgerEntry{
+		{userID: user.ID, amount: models.StartingGold},
+		{userID: models.HouseUserID, amount: -models.StartingGold},
+	}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	user.GoldBalance = models.StartingGold
 	return user, nil
 }
 
@@ -51,11 +70,3 @@ func (r *UserRepo) FindByID(ctx context.Context, id int64) (*models.User, error)
 	}
 	return user, nil
 }
-
-func (r *UserRepo) UpdateGold(ctx context.Context, userID int64, delta int64) error {
-	_, err := r.db.ExecContext(ctx,
-		`UPDATE users SET gold_balance = gold_balance + $1 WHERE id = $2`,
-		delta, userID,
-	)
-	return err
-}
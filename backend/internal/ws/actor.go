@@ -0,0 +1,135 @@
+package ws
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+// backlogWarning is how many jobs can wait for a room before the backlog is
+// logged, and again each time it grows by as many.
+const backlogWarning = 256
+
+// A roomActor owns one room. Every read or write of the room's state runs as
+// a job on the actor's goroutine, one at a time, so jobs need no locks.
+// After each job the actor publishes a RoomInfo snapshot for readers on
+// other goroutines, such as the lobby list.
+//
+// The queue of jobs is unbounded, so a room that falls behind never holds up
+// whoever queues work for it, such as the hub routing other rooms' messages.
+type roomActor struct {
+	room *models.Room
+	info atomic.Pointer[models.RoomInfo]
+
+	mu    sync.Mutex
+	queue []func(*models.Room)
+	wake  chan struct{}
+}
+
+func (a *roomActor) push(fn func(*models.Room)) {
+	a.mu.Lock()
+	a.queue = append(a.queue, fn)
+	if n := len(a.queue); n%backlogWarning == 0 {
+		log.Printf("room %d: %d jobs waiting", a.room.ID, n)
+	}
+	a.mu.Unlock()
+
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (a *roomActor) run() {
+	for range a.wake {
+		for {
+			a.mu.Lock()
+			jobs := a.queue
+			a.queue = nil
+			a.mu.Unlock()
+			if len(jobs) == 0 {
+				break
+			}
+			for _, job := range jobs {
+				job(a.room)
+				info := a.room.ToInfo()
+				a.info.Store(&info)
+			}
+		}
+	}
+}
+
+// actor returns the room's actor, starting its goroutine on first use, or
+// nil if there is no such room.
+func (h *Hub) actor(roomID int) *roomActor {
+	h.mu.RLock()
+	a := h.actors[roomID]
+	h.mu.RUnlock()
+	if a != nil {
+		return a
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if a = h.actors[roomID]; a != nil {
+		return a
+	}
+	room := h.Rooms[roomID]
+	if room == nil {
+		return nil
+	}
+	a = &roomActor{room: room, wake: make(chan struct{}, 1)}
+	info := room.ToInfo()
+	a.info.Store(&info)
+	h.actors[roomID] = a
+	go a.run()
+	return a
+}
+
+// Do queues fn to run on the room's goroutine and reports whether the room
+// exists. It never blocks. Jobs must not wait on their own room.
+func (h *Hub) Do(roomID int, fn func(room *models.Room)) bool {
+	a := h.actor(roomID)
+	if a == nil {
+		return false
+	}
+	a.push(fn)
+	return true
+}
+
+// DoWait is Do that waits for fn to finish. It must not be called from a
+// room's own goroutine.
+func (h *Hub) DoWait(roomID int, fn func(room *models.Room)) bool {
+	done := make(chan struct{})
+	if !h.Do(roomID, func(room *models.Room) {
+		defer close(done)
+		fn(room)
+	}) {
+		return false
+	}
+	<-done
+	return true
+}
+
+// RoomInfo returns the latest public view of a room.
+func (h *Hub) RoomInfo(roomID int) (models.RoomInfo, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.roomInfoLocked(roomID)
+}
+
+// roomInfoLocked must be called with h.mu held. A room without an actor has
+// never been touched since startup and cannot gain one while h.mu is held,
+// so it is safe to read directly.
+func (h *Hub) roomInfoLocked(roomID int) (models.RoomInfo, bool) {
+	if a := h.actors[roomID]; a != nil {
+		return *a.info.Load(), true
+	}
+	room := h.Rooms[roomID]
+	if room == nil {
+		return models.RoomInfo{}, false
+	}
+	return room.ToInfo(), true
+}
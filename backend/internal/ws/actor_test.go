@@ -0,0 +1,45 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/game-playzui/tienlen-server/internal/models"
+)
+
+// A room stuck on a slow job keeps taking work without holding up the
+// sender or any other room, and runs it all in order once it catches up.
+func TestDoDoesNotBlockOnASlowRoom(t *testing.T) {
+	h := NewHub()
+	release := make(chan struct{})
+	h.Do(1, func(*models.Room) { <-release })
+
+	const jobs = 4 * backlogWarning
+	var ran []int
+	queued := make(chan struct{})
+	go func() {
+		for i := 0; i < jobs; i++ {
+			h.Do(1, func(*models.Room) { ran = append(ran, i) })
+		}
+		close(queued)
+	}()
+	select {
+	case <-queued:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Do blocked on a busy room")
+	}
+	if !h.DoWait(2, func(*models.Room) {}) {
+		t.Fatal("room 2 does not exist")
+	}
+
+	close(release)
+	h.DoWait(1, func(*models.Room) {})
+	if len(ran) != jobs {
+		t.Fatalf("%d of %d jobs ran", len(ran), jobs)
+	}
+	for i, n := range ran {
+		if n != i {
+			t.Fatalf("job %d ran as number %d", n, i)
+		}
+	}
+}
@@ -24,6 +24,7 @@ type Client struct {
 	RoomID   int
 	IsBot    bool
 	mu       sync.Mutex
+	closed   bool
 }
 
 func NewClient(hub *Hub, conn *websocket.Conn, userID int64, username string) *Client {
@@ -58,6 +59,44 @@ func (c *Client) GetRoom() int {
 	return c.RoomID
 }
 
+// CompareAndSetRoom moves the client to room to if it is in room from, and
+// reports whether it did. Rooms run on their own goroutines, so a client
+// joining one claims it this way rather than checking GetRoom first.
+func (c *Client) CompareAndSetRoom(from, to int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.RoomID != from {
+		return false
+	}
+	c.RoomID = to
+	return true
+}
+
+// Deliver queues data for the client without blocking. It drops the message
+// if the client's buffer is full or the connection has been closed, so it is
+// safe to call from any goroutine.
+func (c *Client) Deliver(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.Send <- data:
+	default:
+	}
+}
+
+// closeSend closes the Send channel once; later deliveries are dropped.
+func (c *Client) closeSend() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.Send)
+	}
+}
+
 func (c *Client) ReadPump() {
 	defer func() {
 		c.Hub.Unregister <- c
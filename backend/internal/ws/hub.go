@@ -9,6 +9,9 @@ import (
 	"github.com/game-playzui/tienlen-server/internal/models"
 )
 
+// Hub tracks connected clients and routes their messages. Each room's state
+// is owned by that room's goroutine (see Do); the hub goroutine only decodes
+// messages and hands them to the right room.
 type Hub struct {
 	Clients    map[int64]*Client
 	Rooms      map[int]*models.Room
@@ -16,8 +19,18 @@ type Hub struct {
 	Unregister chan *Client
 	Incoming   chan *ClientMessage
 	mu         sync.RWMutex
+	actors     map[int]*roomActor
 
-	OnMessage func(client *Client, msg Message)
+	// OnMessage handles a client message. Messages about a room run on that
+	// room's goroutine; anything else runs on the hub goroutine with a nil
+	// room.
+	OnMessage func(client *Client, room *models.Room, msg Message)
+	// OnConnect is called after a client registers, so it can be put back
+	// into a room it was playing in.
+	OnConnect func(client *Client)
+	// OnDisconnect is called when a client that was in a room drops.
+	// If nil the client simply leaves the room.
+	OnDisconnect func(client *Client, roomID int)
 }
 
 func (h *Hub) RegisterBotClient(client *Client) {
@@ -51,6 +64,7 @@ func NewHub() *Hub {
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
 		Incoming:   make(chan *ClientMessage, 256),
+		actors:     make(map[int]*roomActor),
 	}
 	h.initRooms()
 	return h
@@ -79,39 +93,79 @@ func (h *Hub) Run() {
 		case client := <-h.Register:
 			h.mu.Lock()
 			if existing, ok := h.Clients[client.UserID]; ok {
-				close(existing.Send)
+				existing.closeSend()
+				// The new connection takes over the old one's seat.
+				if roomID := existing.GetRoom(); roomID > 0 {
+					client.CompareAndSetRoom(0, roomID)
+				}
 			}
 			h.Clients[client.UserID] = client
 			h.mu.Unlock()
 			log.Printf("client registered: user=%d username=%s", client.UserID, client.Username)
+			if h.OnConnect != nil {
+				h.OnConnect(client)
+			}
 
 		case client := <-h.Unregister:
 			h.mu.Lock()
+			current := false
 			if c, ok := h.Clients[client.UserID]; ok && c == client {
 				delete(h.Clients, client.UserID)
-				close(client.Send)
+				client.closeSend()
+				current = true
 			}
 			h.mu.Unlock()
 
+			// A connection that was replaced by a newer one no longer owns
+			// its seat, so only the current connection triggers a leave.
 			roomID := client.GetRoom()
-			if roomID > 0 {
-				h.HandlePlayerLeave(client, roomID)
+			if current && roomID > 0 {
+				if h.OnDisconnect != nil {
+					h.OnDisconnect(client, roomID)
+				} else {
+					h.HandlePlayerLeave(client, roomID)
+				}
 			}
 			log.Printf("client unregistered: user=%d", client.UserID)
 
 		case cm := <-h.Incoming:
 			var msg Message
 			if err := json.Unmarshal(cm.Data, &msg); err != nil {
-				cm.Client.Send <- NewErrorMessage("invalid message format")
+				cm.Client.Deliver(NewErrorMessage("invalid message format"))
 				continue
 			}
-			if h.OnMessage != nil {
-				h.OnMessage(cm.Client, msg)
-			}
+			h.route(cm.Client, msg)
 		}
 	}
 }
 
+// route hands a message to the goroutine of the room it is about: the room
+// being joined, or else the sender's current room. Auto-match requests and
+// messages from clients outside any room run on the hub goroutine.
+func (h *Hub) route(client *Client, msg Message) {
+	if h.OnMessage == nil {
+		return
+	}
+	roomID := client.GetRoom()
+	switch msg.Type {
+	case MsgJoinRoom:
+		var p JoinRoomPayload
+		if json.Unmarshal(msg.Payload, &p) == nil {
+			roomID = p.RoomID
+		}
+	case MsgAutoMatch:
+		roomID = 0
+	}
+	if roomID > 0 && h.Do(roomID, func(room *models.Room) {
+		h.OnMessage(client, room, msg)
+	}) {
+		return
+	}
+	h.OnMessage(client, nil, msg)
+}
+
+// GetRoom returns a room by ID. Its state may only be touched before Run
+// starts or from the room's own goroutine.
 func (h *Hub) GetRoom(id int) *models.Room {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -124,22 +178,9 @@ func (h *Hub) GetClient(userID int64) *Client {
 	return h.Clients[userID]
 }
 
-// BroadcastToRoom acquires a read lock on the room before sending.
-// Do NOT call this while already holding the room lock — use BroadcastToRoomHeld instead.
-func (h *Hub) BroadcastToRoom(roomID int, data []byte) {
-	room := h.GetRoom(roomID)
-	if room == nil {
-		return
-	}
-	room.RLock()
-	userIDs := h.collectRoomUserIDs(room)
-	room.RUnlock()
-	h.sendToUsers(userIDs, data)
-}
-
-// BroadcastToRoomHeld sends to all players/spectators in a room.
-// Caller MUST already hold the room lock (read or write).
-func (h *Hub) BroadcastToRoomHeld(room *models.Room, data []byte) {
+// Broadcast sends to all players/spectators in a room.
+// Must be called on the room's goroutine.
+func (h *Hub) Broadcast(room *models.Room, data []byte) {
 	userIDs := h.collectRoomUserIDs(room)
 	h.sendToUsers(userIDs, data)
 }
@@ -159,38 +200,37 @@ func (h *Hub) collectRoomUserIDs(room *models.Room) []int64 {
 
 func (h *Hub) sendToUsers(userIDs []int64, data []byte) {
 	for _, uid := range userIDs {
-		if c := h.GetClient(uid); c != nil {
-			select {
-			case c.Send <- data:
-			default:
-			}
-		}
+		h.SendToClient(uid, data)
 	}
 }
 
 func (h *Hub) SendToClient(userID int64, data []byte) {
 	if c := h.GetClient(userID); c != nil {
-		select {
-		case c.Send <- data:
-		default:
-		}
+		c.Deliver(data)
 	}
 }
 
+// HandlePlayerLeave removes the client's seat or spectator slot on the
+// room's goroutine.
 func (h *Hub) HandlePlayerLeave(client *Client, roomID int) {
-	room := h.GetRoom(roomID)
-	if room == nil {
-		return
-	}
-	room.Lock()
+	h.Do(roomID, func(room *models.Room) {
+		h.RemoveFromRoom(room, client.UserID)
+	})
+}
 
-	idx, _ := room.FindPlayerByUserID(client.UserID)
+// RemoveFromRoom removes a player's seat or spectator slot. If a player
+// leaves mid-game the game is aborted and its ID is returned.
+// Must be called on the room's goroutine.
+func (h *Hub) RemoveFromRoom(room *models.Room, userID int64) (abortedGameID string) {
+	idx, _ := room.FindPlayerByUserID(userID)
 	if idx >= 0 {
 		room.Players[idx] = nil
+		if room.Phase == models.PhaseDealing || room.Phase == models.PhaseDeclaring || room.Phase == models.PhasePlaying {
+			abortedGameID = room.GameID
+		}
 		if room.Phase != models.PhaseLobby {
 			room.Phase = models.PhaseLobby
-			room.TablePlay = nil
-			room.PassCount = 0
+			room.ClearRound()
 			for _, p := range room.Players {
 				if p != nil {
 					p.IsReady = false
@@ -200,12 +240,12 @@ func (h *Hub) HandlePlayerLeave(client *Client, roomID int) {
 			}
 		}
 	} else {
-		room.RemoveSpectator(client.UserID)
+		room.RemoveSpectator(userID)
 	}
 
 	data, _ := NewMessage(MsgRoomUpdate, room.ToInfo())
-	h.BroadcastToRoomHeld(room, data)
-	room.Unlock()
+	h.Broadcast(room, data)
+	return abortedGameID
 }
 
 func (h *Hub) ListRoomInfos() []models.RoomInfo {
@@ -213,10 +253,9 @@ func (h *Hub) ListRoomInfos() []models.RoomInfo {
 	defer h.mu.RUnlock()
 
 	infos := make([]models.RoomInfo, 0, len(h.Rooms))
-	for _, room := range h.Rooms {
-		room.RLock()
-		infos = append(infos, room.ToInfo())
-		room.RUnlock()
+	for id := range h.Rooms {
+		info, _ := h.roomInfoLocked(id)
+		infos = append(infos, info)
 	}
 	return infos
 }
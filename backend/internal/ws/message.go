@@ -6,25 +6,33 @@ type MessageType string
 
 const (
 	// Client -> Server
-	MsgJoinRoom  MessageType = "join_room"
-	MsgLeaveRoom MessageType = "leave_room"
-	MsgReady     MessageType = "ready"
-	MsgPlayCards MessageType = "play_cards"
-	MsgPassTurn  MessageType = "pass_turn"
-	MsgChat      MessageType = "chat"
-	MsgAutoMatch MessageType = "auto_match"
+	MsgJoinRoom      MessageType = "join_room"
+	MsgLeaveRoom     MessageType = "leave_room"
+	MsgReady         MessageType = "ready"
+	MsgPlayCards     MessageType = "play_cards"
+	MsgPassTurn      MessageType = "pass_turn"
+	MsgChat          MessageType = "chat"
+	MsgAutoMatch     MessageType = "auto_match"
+	MsgResumeControl MessageType = "resume_control"
+	MsgDeclareSam    MessageType = "declare_sam"
+	MsgWatchReplay   MessageType = "watch_replay"
+	MsgStopReplay    MessageType = "stop_replay"
 
 	// Server -> Client
-	MsgRoomUpdate  MessageType = "room_update"
-	MsgGameState   MessageType = "game_state"
-	MsgCardDealt   MessageType = "card_dealt"
-	MsgMovePlayed  MessageType = "move_played"
-	MsgTurnChange  MessageType = "turn_change"
-	MsgSettlement  MessageType = "settlement"
-	MsgError       MessageType = "error"
-	MsgChatRelay   MessageType = "chat_relay"
-	MsgRoomList    MessageType = "room_list"
-	MsgMatchFound  MessageType = "match_found"
+	MsgRoomUpdate   MessageType = "room_update"
+	MsgGameState    MessageType = "game_state"
+	MsgCardDealt    MessageType = "card_dealt"
+	MsgMovePlayed   MessageType = "move_played"
+	MsgTurnChange   MessageType = "turn_change"
+	MsgSettlement   MessageType = "settlement"
+	MsgError        MessageType = "error"
+	MsgChatRelay    MessageType = "chat_relay"
+	MsgRoomList     MessageType = "room_list"
+	MsgMatchFound   MessageType = "match_found"
+	MsgPlayerStatus MessageType = "player_status"
+	MsgAutoPlay     MessageType = "auto_play"
+	MsgChop         MessageType = "chop"
+	MsgSamDeclared  MessageType = "sam_declared"
 )
 
 type Message struct {
@@ -36,6 +44,12 @@ type JoinRoomPayload struct {
 	RoomID int `json:"room_id"`
 }
 
+// ReadyPayload may carry a seed the player adds to the next deal, so the
+// shuffle depends on entropy the server did not choose.
+type ReadyPayload struct {
+	ClientSeed string `json:"client_seed,omitempty"`
+}
+
 type PlayCardsPayload struct {
 	Cards []CardPayload `json:"cards"`
 }
@@ -45,6 +59,10 @@ type CardPayload struct {
 	Suit string `json:"suit"`
 }
 
+type DeclareSamPayload struct {
+	Declare bool `json:"declare"`
+}
+
 type ChatPayload struct {
 	Message string `json:"message"`
 	Sender  string `json:"sender,omitempty"`
@@ -54,6 +72,15 @@ type AutoMatchPayload struct {
 	AnteLevel int `json:"ante_level"`
 }
 
+// WatchReplayPayload asks for a finished game to be replayed. Seat picks
+// whose hand to show to someone who did not play in the game; nil watches
+// as a spectator. Speed scales the pace, 1 being as it was played.
+type WatchReplayPayload struct {
+	GameID string  `json:"game_id"`
+	Seat   *int    `json:"seat,omitempty"`
+	Speed  float64 `json:"speed,omitempty"`
+}
+
 type ErrorPayload struct {
 	Message string `json:"error"`
 }
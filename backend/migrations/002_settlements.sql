@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS game_settlements (
+    game_id VARCHAR(64) PRIMARY KEY,
+    room_id INTEGER NOT NULL,
+    winner_user_id BIGINT,
+    total_pot BIGINT NOT NULL,
+    server_fee BIGINT NOT NULL,
+    settled_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1,50 @@
+-- The house account is the counterparty for server fees, bot seats, rewards
+-- and admin adjustments. Its password hash can never match a bcrypt hash.
+INSERT INTO users (id, username, password_hash, gold_balance)
+VALUES (0, '__house__', '!', 0)
+ON CONFLICT (id) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS gold_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL,
+    reference VARCHAR(64) NOT NULL DEFAULT '',
+    memo TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Every transfer posts two or more entries whose amounts sum to zero.
+CREATE TABLE IF NOT EXISTS gold_transactions (
+    id BIGSERIAL PRIMARY KEY,
+    transfer_id BIGINT NOT NULL REFERENCES gold_transfers(id),
+    user_id BIGINT NOT NULL REFERENCES users(id),
+    amount BIGINT NOT NULL,
+    balance_after BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_gold_transactions_user ON gold_transactions(user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_gold_transactions_transfer ON gold_transactions(transfer_id);
+
+-- Open the ledger with each existing balance, funded by the house, so that
+-- every balance is explained by the entries that follow.
+INSERT INTO gold_transfers (kind, reference, memo)
+SELECT 'opening_balance', 'user:' || id, 'balance carried over from before the ledger'
+FROM users
+WHERE id <> 0 AND gold_balance <> 0
+ORDER BY id;
+
+INSERT INTO gold_transactions (transfer_id, user_id, amount, balance_after)
+SELECT t.id, u.id, u.gold_balance, u.gold_balance
+FROM gold_transfers t
+JOIN users u ON t.reference = 'user:' || u.id
+WHERE t.kind = 'opening_balance';
+
+INSERT INTO gold_transactions (transfer_id, user_id, amount, balance_after)
+SELECT t.id, 0, -u.gold_balance, -SUM(u.gold_balance) OVER (ORDER BY t.id)
+FROM gold_transfers t
+JOIN users u ON t.reference = 'user:' || u.id
+WHERE t.kind = 'opening_balance';
+
+UPDATE users
+SET gold_balance = -(SELECT COALESCE(SUM(gold_balance), 0) FROM users WHERE id <> 0)
+WHERE id = 0;
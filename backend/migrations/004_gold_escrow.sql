@@ -0,0 +1,12 @@
+-- Gold held back from a player's available balance while a game is running.
+-- Holds are released when the game is settled or aborted.
+CREATE TABLE IF NOT EXISTS gold_escrows (
+    game_id VARCHAR(64) NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id),
+    amount BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    released_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (game_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_gold_escrows_open ON gold_escrows(user_id) WHERE released_at IS NULL;
@@ -0,0 +1,41 @@
+-- Every game played: how it was dealt, each move and how it ended. Bots
+-- have no user row, so player IDs are not foreign keys.
+CREATE TABLE IF NOT EXISTS games (
+    game_id VARCHAR(64) PRIMARY KEY,
+    room_id INTEGER NOT NULL,
+    rules JSONB NOT NULL,
+    seats SMALLINT NOT NULL,
+    ante_amount BIGINT NOT NULL,
+    first_seat SMALLINT NOT NULL,
+    opening_card JSONB,
+    status VARCHAR(16) NOT NULL DEFAULT 'in_progress',
+    winner_seat SMALLINT,
+    reason VARCHAR(32) NOT NULL DEFAULT '',
+    settlement JSONB,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ended_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS game_players (
+    game_id VARCHAR(64) NOT NULL REFERENCES games(game_id),
+    seat SMALLINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    username VARCHAR(64) NOT NULL,
+    is_bot BOOLEAN NOT NULL,
+    hand JSONB NOT NULL,
+    position SMALLINT,
+    gold_delta BIGINT,
+    PRIMARY KEY (game_id, seat)
+);
+
+CREATE INDEX IF NOT EXISTS idx_game_players_user ON game_players(user_id);
+
+CREATE TABLE IF NOT EXISTS game_moves (
+    game_id VARCHAR(64) NOT NULL REFERENCES games(game_id),
+    seq INTEGER NOT NULL,
+    seat SMALLINT NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    cards JSONB,
+    played_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (game_id, seq)
+);
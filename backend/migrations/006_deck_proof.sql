@@ -0,0 +1,2 @@
+-- The seeds each game was dealt from, so players can deal it again.
+ALTER TABLE games ADD COLUMN IF NOT EXISTS deck_proof JSONB;
@@ -0,0 +1,11 @@
+-- Chops are paid the moment they are made. One row per chop keeps the
+-- payment from being booked twice.
+CREATE TABLE IF NOT EXISTS game_chops (
+    game_id VARCHAR(64) NOT NULL,
+    chop_index INTEGER NOT NULL,
+    from_user_id BIGINT NOT NULL,
+    to_user_id BIGINT NOT NULL,
+    amount BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (game_id, chop_index)
+);